@@ -0,0 +1,260 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// AsyncOption configures MapAsync.
+type AsyncOption func(*asyncArg)
+
+// asyncArg is the argument of the mapAsync operation.
+type asyncArg struct {
+	concurrency int
+	unordered   bool
+	ctx         context.Context
+}
+
+// Unordered emits the results of MapAsync as they complete, instead of in the order of the elements.
+func Unordered() AsyncOption {
+	return func(arg *asyncArg) {
+		arg.unordered = true
+	}
+}
+
+// WithContext cancels MapAsync when ctx is done, the error of ctx stops the stream.
+// The context is passed to map functions that take one.
+func WithContext(ctx context.Context) AsyncOption {
+	return func(arg *asyncArg) {
+		arg.ctx = ctx
+	}
+}
+
+// MapAsync operation. Map one to one on a pool of concurrency goroutines, the results are in the order of the
+// elements unless the Unordered option is given. At most concurrency elements are mapped or waiting to be
+// emitted at a time. An error returned by mapFunc stops the stream and is reported by Err,
+// unless it is handled by OnErrorResume, OnErrorSkip or OnErrorDeadLetter. When the stream stops early or ctx is
+// done, MapAsync doesn't wait for a source blocked on its next element, such as a channel: the element it
+// eventually reads is dropped. A panic in mapFunc is raised again in the goroutine consuming the stream.
+// mapFunc: func(o T1) T2, func(o T1) (T2, error) or func(ctx context.Context, o T1) (T2, error)
+func (s *Stream) MapAsync(mapFunc interface{}, concurrency int, opts ...AsyncOption) *Stream {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	arg := asyncArg{concurrency: concurrency, ctx: context.Background()}
+	for _, opt := range opts {
+		opt(&arg)
+	}
+	funcValue := reflect.ValueOf(mapFunc)
+	if !isAsyncFunc(funcValue) {
+		s.failOp(fmt.Errorf("stream: MapAsync function must be like func(o T1) T2, func(o T1) (T2, error) "+
+			"or func(ctx context.Context, o T1) (T2, error), not %T", mapFunc))
+		return s
+	}
+	s.ops = append(s.ops, op{typ: "mapAsync", fun: funcValue, arg: arg})
+	return s
+}
+
+// isAsyncFunc reports if fun is a map function MapAsync can call.
+func isAsyncFunc(fun reflect.Value) bool {
+	if fun.Kind() != reflect.Func {
+		return false
+	}
+	t := fun.Type()
+	in := t.NumIn() == 1 || t.NumIn() == 2 && t.In(0) == contextType
+	out := t.NumOut() == 1 || t.NumOut() == 2 && t.Out(1) == errorType
+	return in && out
+}
+
+type asyncJob struct {
+	seq int
+	it  interface{}
+}
+
+type asyncResult struct {
+	seq   int
+	in    interface{}
+	it    interface{}
+	err   error
+	panic interface{} // of the map function, raised again in the goroutine pulling the results
+}
+
+// callAsync calls a map function that may take a context and return an error.
+func callAsync(ctx context.Context, fun reflect.Value, it interface{}) (interface{}, error) {
+	var out []reflect.Value
+	if fun.Type().NumIn() == 2 {
+		out = fun.Call([]reflect.Value{reflect.ValueOf(ctx), convertValue(it, fun.Type().In(1))})
+	} else {
+		out = call(fun, it)
+	}
+	if len(out) == 2 {
+		if err, _ := out[1].Interface().(error); err != nil {
+			return nil, err
+		}
+	}
+	return out[0].Interface(), nil
+}
+
+func mapAsyncStage(next iterator, op op, s *Stream) (iterator, func(), *feeder) {
+	arg := op.arg.(asyncArg)
+	ctx, cancel := context.WithCancel(arg.ctx)
+	tokens := make(chan struct{}, arg.concurrency)
+	jobs := make(chan asyncJob)
+	results := make(chan asyncResult, arg.concurrency)
+
+	var workers sync.WaitGroup
+	f := newFeeder()
+	go func() {
+		defer close(jobs)
+		defer close(f.exited)
+		for seq := 0; ; seq++ {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			it, ok := f.pull(next)
+			if !ok {
+				return
+			}
+			select {
+			case jobs <- asyncJob{seq: seq, it: it}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for i := 0; i < arg.concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				var job asyncJob
+				var ok bool
+				select {
+				case job, ok = <-jobs:
+					if !ok {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+				r := asyncResult{seq: job.seq, in: job.it}
+				func() {
+					defer func() { r.panic = recover() }()
+					r.it, r.err = callAsync(ctx, op.fun, job.it)
+				}()
+				select {
+				case results <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			cancel()
+			f.stop()
+			workers.Wait()
+		})
+	}
+	pending := make(map[int]asyncResult)
+	want := 0
+	return func() (interface{}, bool) {
+		for {
+			r, found := pending[want]
+			if arg.unordered {
+				for _, r = range pending {
+					found = true
+					break
+				}
+			}
+			if found {
+				delete(pending, r.seq)
+				want++
+				<-tokens
+				if r.panic != nil {
+					stop()
+					panic(r.panic)
+				}
+				if r.err == nil {
+					return r.it, true
+				}
+				if op.onErr == nil {
+					stop()
+					s.fail(r.err)
+					return nil, false
+				}
+				if it, emit := op.onErr(r.in, r.err); emit {
+					return it, true
+				}
+				continue
+			}
+			select {
+			case r, ok := <-results:
+				if !ok {
+					stop()
+					return nil, false
+				}
+				pending[r.seq] = r
+			case <-arg.ctx.Done():
+				stop()
+				s.fail(arg.ctx.Err())
+				return nil, false
+			}
+		}
+	}, stop, f
+}
+
+// feeder is the goroutine of a stage that pulls its input in the background. Stopping the stage waits for it,
+// unless it is blocked in next: a source such as a channel may never yield again, so the feeder is left to drop
+// what next returns and exit on its own.
+type feeder struct {
+	mu      sync.Mutex
+	reading bool
+	stopped bool
+	exited  chan struct{} // closed by the goroutine when it returns
+}
+
+func newFeeder() *feeder {
+	return &feeder{exited: make(chan struct{})}
+}
+
+// pull returns the next element, more is false once the stage is stopped.
+func (f *feeder) pull(next iterator) (it interface{}, more bool) {
+	f.mu.Lock()
+	if f.stopped {
+		f.mu.Unlock()
+		return nil, false
+	}
+	f.reading = true
+	f.mu.Unlock()
+	it, more = next()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reading = false
+	return it, more && !f.stopped
+}
+
+// stop makes pull return false, then waits for the goroutine to exit unless it is blocked in next.
+// The goroutine must also be released from its other waits, by closing a channel or cancelling a context.
+func (f *feeder) stop() {
+	f.mu.Lock()
+	f.stopped = true
+	reading := f.reading
+	f.mu.Unlock()
+	if !reading {
+		<-f.exited
+	}
+}
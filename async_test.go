@@ -0,0 +1,182 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMapAsync(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	stream, _ := GenN(50, func(i int) int { return i })
+
+	var running, maxRunning int32
+	var result []int
+	stream.MapAsync(func(i int) int {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(50-i) * 100 * time.Microsecond)
+		atomic.AddInt32(&running, -1)
+		return i * 2
+	}, 4).ToSlice(&result)
+
+	if len(result) != 50 {
+		t.Fatalf("expected 50 results, got %d", len(result))
+	}
+	for i, r := range result {
+		if r != i*2 {
+			t.Fatalf("result %d out of order: %v", i, result)
+		}
+	}
+	if maxRunning > 4 {
+		t.Errorf("expected at most 4 concurrent calls, got %d", maxRunning)
+	}
+	fmt.Printf("\t%v\n", result[:5])
+}
+
+func TestMapAsyncUnordered(t *testing.T) {
+	stream, _ := Ints(3, 1, 2)
+	var result []int64
+	stream.MapAsync(func(i int64) int64 {
+		time.Sleep(time.Duration(i) * 10 * time.Millisecond)
+		return i
+	}, 3, Unordered()).ToSlice(&result)
+	if fmt.Sprint(result) != "[1 2 3]" {
+		t.Errorf("expected results as completed, got %v", result)
+	}
+}
+
+func TestMapAsyncErr(t *testing.T) {
+	stream, _ := GenN(100, func(i int) int { return i })
+	n := stream.MapAsync(func(i int) (int, error) {
+		if i == 10 {
+			return 0, errors.New("failed on 10")
+		}
+		return i, nil
+	}, 4).Count()
+	if n != 10 || stream.Err() == nil {
+		t.Errorf("expected 10 elements before the error, got %d, err %v", n, stream.Err())
+	}
+	fmt.Println(t.Name()+":", stream.Err())
+}
+
+func TestMapAsyncContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, _ := GenN(100, func(i int) int { return i })
+	n := stream.MapAsync(func(ctx context.Context, i int) (int, error) {
+		if i == 5 {
+			cancel()
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+		return i, nil
+	}, 2, WithContext(ctx)).Count()
+	if n >= 100 || !errors.Is(stream.Err(), context.Canceled) {
+		t.Errorf("expected canceled stream, got %d elements, err %v", n, stream.Err())
+	}
+}
+
+func TestMapAsyncLimit(t *testing.T) {
+	var calls int32
+	stream, _ := GenN(1000, func(i int) int { return i })
+	var result []int
+	stream.MapAsync(func(i int) int {
+		atomic.AddInt32(&calls, 1)
+		return i
+	}, 4).Limit(3).ToSlice(&result)
+	if fmt.Sprint(result) != "[0 1 2]" || atomic.LoadInt32(&calls) > 10 {
+		t.Errorf("unexpected result %v after %d calls", result, calls)
+	}
+}
+
+// stalled returns a channel that yields the values and then blocks forever, like a source waiting for input.
+func stalled(values ...int) chan int {
+	ch := make(chan int)
+	go func() {
+		for _, v := range values {
+			ch <- v
+		}
+	}()
+	return ch
+}
+
+// within fails the test if run doesn't return within d.
+func within(t *testing.T, d time.Duration, run func()) {
+	t.Helper()
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		run()
+	}()
+	select {
+	case <-finished:
+	case <-time.After(d):
+		t.Fatalf("still running after %v", d)
+	}
+}
+
+func TestMapAsyncStalledSource(t *testing.T) {
+	stream, _ := FromChan(stalled(1))
+	var n int
+	within(t, 2*time.Second, func() {
+		n = stream.MapAsync(func(i int) int { return i }, 2).Peek(func(int) {
+			time.Sleep(10 * time.Millisecond)
+		}).Limit(1).Count()
+	})
+	if n != 1 {
+		t.Errorf("expected 1 element, got %d", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	stream, _ = FromChan(stalled(1))
+	within(t, 2*time.Second, func() {
+		n = stream.MapAsync(func(i int) int { return i }, 2, WithContext(ctx)).Count()
+	})
+	if n != 1 || !errors.Is(stream.Err(), context.Canceled) {
+		t.Errorf("expected 1 element before the cancellation, got %d, %v", n, stream.Err())
+	}
+}
+
+func TestMapAsyncInvalidFunc(t *testing.T) {
+	for _, mapFunc := range []interface{}{func(x, y int) int { return x }, func(i int) {}, func(i int) (int, bool) {
+		return i, true
+	}, nil} {
+		stream, _ := GenN(2, func(i int) int { return i })
+		err := stream.MapAsync(mapFunc, 2).ToSlice(&[]int{})
+		if err == nil || !strings.Contains(err.Error(), "MapAsync function must be like") {
+			t.Errorf("%T: expected an invalid function error, got %v", mapFunc, err)
+		}
+	}
+}
+
+func TestMapAsyncNil(t *testing.T) {
+	stream, _ := Of("a", nil)
+	var result []string
+	err := stream.MapAsync(func(ctx context.Context, s string) (string, error) {
+		return s + "!", nil
+	}, 2).ToSlice(&result)
+	if err != nil || fmt.Sprint(result) != "[a! !]" {
+		t.Errorf("expected nil to be passed as the zero value, got %q, %v", result, err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "boom") {
+			t.Errorf("expected the panic of the map function, got %v", r)
+		}
+	}()
+	stream, _ = Ints(1, 2)
+	stream.MapAsync(func(i int64) int64 { panic("boom") }, 2).Count()
+}
@@ -0,0 +1,156 @@
+package stream
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// FromChan create a stream from the values received from a channel, until it is closed.
+// Values are received lazily, so the stream can be consumed only once.
+func FromChan(ch interface{}) (*Stream, error) {
+	chanValue := reflect.ValueOf(ch)
+	if chanValue.Kind() != reflect.Chan || chanValue.Type().ChanDir()&reflect.RecvDir == 0 {
+		return nil, errors.New("the type of ch parameter must be a receivable Chan")
+	}
+	s := &Stream{ops: make([]op, 0), res: chanValue.Type().Elem()}
+	s.src = &source{next: func() (interface{}, bool, error) {
+		v, ok := chanValue.Recv()
+		if !ok {
+			return nil, false, nil
+		}
+		return v.Interface(), true, nil
+	}}
+	return s, nil
+}
+
+// batchArg is the argument of the batch operation, typ is the type of the elements batched.
+type batchArg struct {
+	size int
+	wait time.Duration
+	typ  reflect.Type
+}
+
+// Batch operation. Group the elements into slices of at most maxSize elements, the last one may be shorter.
+// If maxWait is positive, a batch is also emitted once maxWait has passed since its first element arrived,
+// even if the source is waiting for the next one, as a channel does. The slices are of the element type,
+// []interface{} if it isn't known. When the stream stops early, a timed Batch doesn't wait for a source blocked on
+// its next element: the element it eventually reads is dropped.
+func (s *Stream) Batch(maxSize int, maxWait time.Duration) *Stream {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	typ := s.elemType()
+	if typ == nil {
+		typ = anyType
+	}
+	s.ops = append(s.ops, op{typ: "batch", arg: batchArg{size: maxSize, wait: maxWait, typ: typ}})
+	return s
+}
+
+// ForEachBatch operation. Call actFunc with batches of at most size elements, see Batch.
+// It stops at the first error returned by actFunc and returns it, or the error of the stream.
+// actFunc: func(batch []T) or func(batch []T) error
+func (s *Stream) ForEachBatch(size int, actFunc interface{}) error {
+	if size < 1 {
+		size = 1
+	}
+	typ := s.elemType()
+	if typ == nil {
+		typ = anyType
+	}
+	funcValue := reflect.ValueOf(actFunc)
+	next, done := s.iterator()
+	defer done()
+	next = batchStage(next, op{typ: "batch", arg: batchArg{size: size, typ: typ}})
+	for it, ok := next(); ok; it, ok = next() {
+		out := call(funcValue, it)
+		if len(out) == 1 {
+			if err, _ := out[0].Interface().(error); err != nil {
+				return err
+			}
+		}
+	}
+	return s.Err()
+}
+
+func batchStage(next iterator, op op) iterator {
+	arg := op.arg.(batchArg)
+	sliceType := reflect.SliceOf(arg.typ)
+	return func() (interface{}, bool) {
+		batch := reflect.MakeSlice(sliceType, 0, arg.size)
+		for batch.Len() < arg.size {
+			it, ok := next()
+			if !ok {
+				break
+			}
+			batch = reflect.Append(batch, convertValue(it, arg.typ))
+		}
+		if batch.Len() == 0 {
+			return nil, false
+		}
+		return batch.Interface(), true
+	}
+}
+
+// timedBatchStage pulls the elements in the background, so that a batch can be emitted while waiting for one.
+func timedBatchStage(next iterator, op op, clock Clock) (iterator, func(), *feeder) {
+	arg := op.arg.(batchArg)
+	sliceType := reflect.SliceOf(arg.typ)
+	items := make(chan interface{})
+	quit := make(chan struct{})
+
+	f := newFeeder()
+	go func() {
+		defer close(items)
+		defer close(f.exited)
+		for {
+			it, ok := f.pull(next)
+			if !ok {
+				return
+			}
+			select {
+			case items <- it:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(quit)
+			f.stop()
+		})
+	}
+	exhausted := false
+	return func() (interface{}, bool) {
+		if exhausted {
+			return nil, false
+		}
+		batch := reflect.MakeSlice(sliceType, 0, arg.size)
+		var timeout <-chan time.Time
+		for batch.Len() < arg.size {
+			select {
+			case it, ok := <-items:
+				if !ok {
+					exhausted = true
+					stop()
+					if batch.Len() == 0 {
+						return nil, false
+					}
+					return batch.Interface(), true
+				}
+				if batch.Len() == 0 {
+					timeout = clock.After(arg.wait)
+				}
+				batch = reflect.Append(batch, convertValue(it, arg.typ))
+			case <-timeout:
+				return batch.Interface(), true
+			}
+		}
+		return batch.Interface(), true
+	}, stop, f
+}
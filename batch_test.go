@@ -0,0 +1,124 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	stream, _ := New([]int{1, 2, 3, 4, 5, 6, 7})
+	var result [][]int
+	if err := stream.Batch(3, 0).ToSlice(&result); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(result) != "[[1 2 3] [4 5 6] [7]]" {
+		t.Errorf("unexpected batches %v", result)
+	}
+	fmt.Println(t.Name()+":", result)
+}
+
+func TestBatchTimeout(t *testing.T) {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+		ch <- 2
+		time.Sleep(200 * time.Millisecond)
+		ch <- 3
+		ch <- 4
+		ch <- 5
+		close(ch)
+	}()
+	stream, _ := FromChan(ch)
+	var result [][]int
+	stream.Batch(2, 50*time.Millisecond).ForEach(func(batch []int) {
+		result = append(result, batch)
+	})
+	if fmt.Sprint(result) != "[[1 2] [3 4] [5]]" {
+		t.Errorf("unexpected batches %v", result)
+	}
+
+	ch = make(chan int)
+	go func() {
+		ch <- 1
+		time.Sleep(200 * time.Millisecond)
+		ch <- 2
+		close(ch)
+	}()
+	stream, _ = FromChan(ch)
+	result = nil
+	stream.Batch(10, 50*time.Millisecond).ToSlice(&result)
+	if fmt.Sprint(result) != "[[1] [2]]" {
+		t.Errorf("expected a batch flushed by time, got %v", result)
+	}
+	fmt.Println(t.Name()+":", result)
+}
+
+func TestForEachBatch(t *testing.T) {
+	stream, _ := GenN(10, func(i int) int { return i })
+	var sizes []int
+	err := stream.ForEachBatch(4, func(batch []interface{}) {
+		sizes = append(sizes, len(batch))
+	})
+	if err != nil || fmt.Sprint(sizes) != "[4 4 2]" {
+		t.Errorf("unexpected batch sizes %v, %v", sizes, err)
+	}
+
+	errFull := errors.New("full")
+	stream, _ = GenN(10, func(i int) int { return i })
+	calls := 0
+	err = stream.ForEachBatch(4, func(batch []interface{}) error {
+		calls++
+		return errFull
+	})
+	if err != errFull || calls != 1 {
+		t.Errorf("expected to stop at the first error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestForEachBatchKeepsOps(t *testing.T) {
+	stream, _ := Ints(1, 2, 3)
+	batches := 0
+	stream.ForEachBatch(2, func(batch []int64) { batches++ })
+	if n := stream.Count(); batches != 2 || n != 3 {
+		t.Errorf("expected 2 batches and then 3 elements, got %d and %d", batches, n)
+	}
+}
+
+func TestBatchStalledSource(t *testing.T) {
+	stream, _ := FromChan(stalled(1, 2))
+	var n int
+	within(t, 2*time.Second, func() {
+		n = stream.Batch(2, 10*time.Millisecond).Limit(1).Count()
+	})
+	if n != 1 {
+		t.Errorf("expected 1 batch, got %d", n)
+	}
+
+	stream, _ = FromChan(stalled(1, 2, 3))
+	var batches [][]int
+	within(t, 2*time.Second, func() {
+		stream.Batch(2, 10*time.Millisecond).Limit(2).ToSlice(&batches)
+	})
+	if fmt.Sprint(batches) != "[[1 2] [3]]" {
+		t.Errorf("unexpected batches %v", batches)
+	}
+}
+
+func TestFromChan(t *testing.T) {
+	ch := make(chan string, 3)
+	ch <- "a"
+	ch <- "b"
+	ch <- "c"
+	close(ch)
+	stream, _ := FromChan(ch)
+	var result []string
+	stream.Limit(2).ToSlice(&result)
+	if fmt.Sprint(result) != "[a b]" {
+		t.Errorf("unexpected elements %v", result)
+	}
+	if _, err := FromChan(make(chan<- int)); err == nil {
+		t.Errorf("expected an error for a send-only channel")
+	}
+}
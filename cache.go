@@ -0,0 +1,56 @@
+package stream
+
+import "errors"
+
+// ErrStreamConsumed is the error of a single-use stream run a second time.
+var ErrStreamConsumed = errors.New("stream has already been operated upon")
+
+// cache is the argument of the cache operation, the elements computed by its first run.
+type cache struct {
+	data  []interface{}
+	valid bool
+}
+
+// SingleUse makes the stream single-use: its source is read at most once, and running the stream again
+// fails with ErrStreamConsumed, unless the operations before a Cache are already cached.
+// Streams from slices are reusable by default, streams from readers, rows or walks are always single-use.
+func (s *Stream) SingleUse() *Stream {
+	s.once = true
+	return s
+}
+
+// Cache operation. Keep the elements at this point of the pipeline the first time the stream runs, later runs
+// start from them instead of running the operations before Cache again, including side effects like Peek and Call.
+func (s *Stream) Cache() *Stream {
+	s.ops = append(s.ops, op{typ: "cache", arg: &cache{}})
+	return s
+}
+
+// Invalidate drops the elements kept by the Cache operations, the next run computes them again.
+func (s *Stream) Invalidate() *Stream {
+	for _, op := range s.ops {
+		if c, ok := op.arg.(*cache); ok {
+			c.data, c.valid = nil, false
+		}
+	}
+	return s
+}
+
+// lastCache returns the index of the last cache operation holding elements, or -1.
+func lastCache(ops []op) int {
+	for i := len(ops) - 1; i >= 0; i-- {
+		if c, ok := ops[i].arg.(*cache); ok && c.valid {
+			return i
+		}
+	}
+	return -1
+}
+
+func cacheStage(next iterator, op op, s *Stream) iterator {
+	c := op.arg.(*cache)
+	data := drain(next)
+	if s.Err() == nil {
+		c.data, c.valid = data, true
+	}
+	return sliceIterator(data)
+}
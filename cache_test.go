@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	students := createStudents()
+	stream, _ := New(students)
+
+	peeks, calls := 0, 0
+	stream.Peek(func(s student) {
+		peeks++
+	}).Call(func() {
+		calls++
+	}).Filter(func(s student) bool {
+		return s.age > 18
+	}).Cache()
+
+	count := stream.Count()
+	var older []student
+	stream.ToSlice(&older)
+	if peeks != len(students) || calls != 1 {
+		t.Errorf("expected ops to run once, got %d peeks and %d calls", peeks, calls)
+	}
+	if len(older) != count {
+		t.Errorf("expected %d students, got %d", count, len(older))
+	}
+
+	stream.Invalidate()
+	if stream.Count() != count || calls != 2 {
+		t.Errorf("expected ops to run again after Invalidate, got %d calls", calls)
+	}
+	fmt.Printf("\t%d %d %d\n", count, peeks, calls)
+}
+
+func TestCacheAfterOps(t *testing.T) {
+	stream, _ := Ints(1, 2, 3, 4)
+	maps := 0
+	stream.Map(func(i int64) int64 {
+		maps++
+		return i * 10
+	}).Cache().Filter(func(i int64) bool {
+		return i > 20
+	})
+	if stream.Count() != 2 || stream.Sum() != int64(70) || maps != 4 {
+		t.Errorf("unexpected result, %d maps", maps)
+	}
+}
+
+func TestSingleUse(t *testing.T) {
+	stream, _ := Ints(1, 2, 3)
+	stream.SingleUse()
+	if n := stream.Count(); n != 3 {
+		t.Errorf("expected 3, got %d", n)
+	}
+	if n := stream.Count(); n != 0 || stream.Err() != ErrStreamConsumed {
+		t.Errorf("expected consumed error, got %d %v", n, stream.Err())
+	}
+
+	reader, _ := FromJSONLines(strings.NewReader("1\n2\n3\n"), 0)
+	reader.Cache()
+	if reader.Count() != 3 || reader.Sum() != 6 || reader.Err() != nil {
+		t.Errorf("expected cached reader to be reusable, err %v", reader.Err())
+	}
+
+	reader, _ = FromJSONLines(strings.NewReader("1\n2\n3\n"), 0)
+	reader.Count()
+	reader.Count()
+	fmt.Println(t.Name()+":", reader.Err())
+}
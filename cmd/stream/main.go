@@ -0,0 +1,319 @@
+// Command stream reads records as NDJSON, JSON arrays or CSV, runs them through a pipeline of stream
+// operations and writes the result as NDJSON, CSV or a table.
+//
+// Usage:
+//
+//	stream [flags] [file ...]
+//
+// The records are read from the files, one after the other, or from the standard input. The input format is
+// guessed from the file extension, or from the first character of the standard input, unless -in is given.
+// The operations are applied in this order: -q, -where, -sort, -skip, -limit, -field, -distinct, then
+// -group or -count. For example:
+//
+//	stream -q 'where age > 20 | sort by age desc' -limit 5 -out table people.csv
+//	stream -where 'status == "failed"' -group service logs.ndjson
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tk103331/stream"
+)
+
+// options are the flags of the command.
+type options struct {
+	in, out  string
+	comma    string
+	query    string
+	where    string
+	field    string
+	sort     string
+	desc     bool
+	distinct bool
+	group    string
+	count    bool
+	limit    int
+	skip     int
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	var usage usageError
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		// The usage was asked for with -h, it isn't an error.
+		os.Exit(0)
+	case errors.As(err, &usage):
+		fmt.Fprintln(os.Stderr, "stream:", err)
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "stream:", err)
+		os.Exit(1)
+	}
+}
+
+// where is the query stage the -where condition is parsed as.
+const where = "where "
+
+// usageError is an error in the flags.
+type usageError struct {
+	error
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	var opts options
+	flags := flag.NewFlagSet("stream", flag.ContinueOnError)
+	flags.StringVar(&opts.in, "in", "", "input format: ndjson, json or csv")
+	flags.StringVar(&opts.out, "out", "ndjson", "output format: ndjson, csv or table")
+	flags.StringVar(&opts.comma, "comma", ",", "field delimiter of CSV input and output")
+	flags.StringVar(&opts.query, "q", "", "query, such as 'where age > 20 | sort by name | select name, age'")
+	flags.StringVar(&opts.where, "where", "", "keep the records matching a query condition, such as 'age > 20'")
+	flags.StringVar(&opts.field, "field", "", "map the records to the value at a field path")
+	flags.StringVar(&opts.sort, "sort", "", "sort the records by the value at a field path")
+	flags.BoolVar(&opts.desc, "desc", false, "sort in descending order")
+	flags.BoolVar(&opts.distinct, "distinct", false, "drop the records equal to a previous one")
+	flags.StringVar(&opts.group, "group", "", "count the records by the value at a field path")
+	flags.BoolVar(&opts.count, "count", false, "write the number of records")
+	flags.IntVar(&opts.limit, "limit", -1, "keep the first n records")
+	flags.IntVar(&opts.skip, "skip", 0, "drop the first n records")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err}
+	}
+	if len([]rune(opts.comma)) != 1 {
+		return usageError{fmt.Errorf("-comma must be one character, not %q", opts.comma)}
+	}
+	if opts.out != "ndjson" && opts.out != "csv" && opts.out != "table" {
+		return usageError{fmt.Errorf("unknown output format %q", opts.out)}
+	}
+
+	s, closeAll, err := open(flags.Args(), stdin, opts)
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+	if s, err = pipeline(s, opts); err != nil {
+		return err
+	}
+
+	switch {
+	case opts.count:
+		n := s.Count()
+		if err := s.Err(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(stdout, n)
+		return err
+	case opts.group != "":
+		groups := s.GroupByField(opts.group)
+		if err := s.Err(); err != nil {
+			return err
+		}
+		if s, err = counts(groups); err != nil {
+			return err
+		}
+	}
+	return write(s, stdout, opts)
+}
+
+// open returns a stream of the records of the files, or of stdin if there is none.
+func open(files []string, stdin io.Reader, opts options) (*stream.Stream, func(), error) {
+	if len(files) == 0 {
+		s, err := read(stdin, opts.in, opts)
+		return s, func() {}, err
+	}
+	var closers []io.Closer
+	closeAll := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+	streams := make([]*stream.Stream, len(files))
+	for i, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		closers = append(closers, f)
+		format := opts.in
+		if format == "" {
+			format = formatOf(name)
+		}
+		if streams[i], err = read(f, format, opts); err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	s, err := stream.Concat(streams...)
+	return s, closeAll, err
+}
+
+// formatOf guesses the format of a file from its extension, empty if unknown.
+func formatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return "csv"
+	case ".json":
+		return "json"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	}
+	return ""
+}
+
+// read returns a stream of the records of r, an empty format is guessed from the first character.
+func read(r io.Reader, format string, opts options) (*stream.Stream, error) {
+	br := bufio.NewReader(r)
+	if format == "" {
+		format = "ndjson"
+		for {
+			c, _, err := br.ReadRune()
+			if err != nil {
+				break
+			}
+			if !strings.ContainsRune(" \t\r\n", c) {
+				if c == '[' {
+					format = "json"
+				}
+				br.UnreadRune()
+				break
+			}
+		}
+	}
+	switch format {
+	case "ndjson":
+		return stream.FromJSONLines(br, map[string]interface{}{})
+	case "json":
+		return stream.FromJSONArray(br, map[string]interface{}{})
+	case "csv":
+		return stream.FromCSV(br, map[string]interface{}{}, &stream.CSVOptions{Comma: []rune(opts.comma)[0]})
+	}
+	return nil, fmt.Errorf("unknown input format %q", format)
+}
+
+// pipeline adds the operations of the flags to the stream.
+func pipeline(s *stream.Stream, opts options) (*stream.Stream, error) {
+	if opts.query != "" {
+		q, err := stream.ParseQuery(opts.query)
+		if err != nil {
+			return nil, usageError{err}
+		}
+		q.Apply(s)
+	}
+	if opts.where != "" {
+		q, err := stream.ParseQuery(where + opts.where)
+		if err != nil {
+			// Report the position in the condition rather than in the query made of it.
+			var qe *stream.QueryError
+			if errors.As(err, &qe) {
+				qe.Query = opts.where
+				if qe.Pos -= len(where); qe.Pos < 0 {
+					qe.Pos = 0
+				}
+			}
+			return nil, usageError{err}
+		}
+		q.Apply(s)
+	}
+	if opts.sort != "" {
+		if opts.desc {
+			s.SortByFieldDesc(opts.sort)
+		} else {
+			s.SortByField(opts.sort)
+		}
+	}
+	if opts.skip > 0 {
+		s.Skip(opts.skip)
+	}
+	if opts.limit >= 0 {
+		s.Limit(opts.limit)
+	}
+	if opts.field != "" {
+		s.Pluck(opts.field)
+	}
+	if opts.distinct {
+		s.Distinct(func(a, b interface{}) bool {
+			return reflect.DeepEqual(a, b)
+		})
+	}
+	return s, nil
+}
+
+// counts returns a stream of records {"key": key, "count": n} of the groups, in ascending order of keys.
+func counts(groups map[interface{}][]interface{}) (*stream.Stream, error) {
+	rows := make([]map[string]interface{}, 0, len(groups))
+	for key, group := range groups {
+		rows = append(rows, map[string]interface{}{"key": key, "count": len(group)})
+	}
+	s, err := stream.New(rows)
+	if err != nil {
+		return nil, err
+	}
+	return s.SortByField("key"), nil
+}
+
+// write writes the records of the stream in the output format. The values that aren't records are written
+// as records {"value": value} to CSV and tables.
+func write(s *stream.Stream, w io.Writer, opts options) error {
+	if opts.out == "ndjson" {
+		return s.ToJSONLines(w)
+	}
+	s.Map(func(it interface{}) map[string]interface{} {
+		if m, ok := it.(map[string]interface{}); ok {
+			return m
+		}
+		return map[string]interface{}{"value": it}
+	})
+	switch opts.out {
+	case "csv":
+		return s.ToCSV(w, &stream.CSVOptions{Comma: []rune(opts.comma)[0]})
+	case "table":
+		var rows []map[string]interface{}
+		if err := s.ToSlice(&rows); err != nil {
+			return err
+		}
+		return writeTable(w, rows)
+	}
+	return fmt.Errorf("unknown output format %q", opts.out)
+}
+
+// writeTable writes the rows as aligned columns, the columns are the sorted keys of all the rows.
+func writeTable(w io.Writer, rows []map[string]interface{}) error {
+	seen := make(map[string]bool)
+	columns := make([]string, 0)
+	for _, row := range rows {
+		for key := range row {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	sort.Strings(columns)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+	cells := make([]string, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			cells[i] = ""
+			if value, ok := row[column]; ok && value != nil {
+				cells[i] = fmt.Sprint(value)
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const people = `{"name": "Tom", "age": 21, "city": "Paris"}
+{"name": "Kate", "age": 31, "city": "Lyon"}
+{"name": "King", "age": 45, "city": "Paris"}
+{"name": "Lucy", "age": 19}
+`
+
+func runString(t *testing.T, input string, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := run(args, strings.NewReader(input), &out); err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+	return out.String()
+}
+
+func TestQuery(t *testing.T) {
+	out := runString(t, people, "-q", `where age > 20 and name ~ "^K" | sort by age desc | select name, age`)
+	expected := `{"age":45,"name":"King"}` + "\n" + `{"age":31,"name":"Kate"}` + "\n"
+	if out != expected {
+		t.Errorf("expected\n%s, got\n%s", expected, out)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-where", "age >= 21", "-sort", "name", "-field", "name"}, "\"Kate\"\n\"King\"\n\"Tom\"\n"},
+		{[]string{"-sort", "age", "-desc", "-skip", "1", "-limit", "2", "-field", "age"}, "31\n21\n"},
+		{[]string{"-field", "city", "-distinct", "-out", "csv"}, "value\nParis\nLyon\n\n"},
+		{[]string{"-where", "city == 'Paris'", "-count"}, "2\n"},
+		{[]string{"-group", "city", "-out", "csv"}, "count,key\n1,\n1,Lyon\n2,Paris\n"},
+	}
+	for _, c := range cases {
+		if out := runString(t, people, c.args...); out != c.expected {
+			t.Errorf("%v: expected\n%q, got\n%q", c.args, c.expected, out)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	csv := "name;age\nTom;21\nKate;31\n"
+	out := runString(t, csv, "-in", "csv", "-comma", ";", "-where", "age > 25", "-out", "csv")
+	if out != "age;name\n31;Kate\n" {
+		t.Errorf("unexpected csv %q", out)
+	}
+
+	out = runString(t, "{\"a\": 1}\n{\"a\": 2, \"b\": 3}\n", "-out", "csv")
+	if out != "a,b\n1,\n2,3\n" {
+		t.Errorf("expected the columns of all the records, got %q", out)
+	}
+
+	array := `[{"name": "Tom", "age": 21}, {"name": "Kate", "age": 31}]`
+	out = runString(t, array, "-sort", "age", "-desc", "-out", "table")
+	expected := "AGE  NAME\n31   Kate\n21   Tom\n"
+	if out != expected {
+		t.Errorf("expected table\n%s, got\n%s", expected, out)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.csv"), []byte("name,age\nTom,21\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "b.ndjson"), []byte(`{"name": "Kate", "age": 31}`+"\n"), 0o644)
+	var out bytes.Buffer
+	err := run([]string{"-field", "name", filepath.Join(dir, "a.csv"), filepath.Join(dir, "b.ndjson")}, nil, &out)
+	if err != nil || out.String() != "\"Tom\"\n\"Kate\"\n" {
+		t.Errorf("unexpected output %q, %v", out.String(), err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cases := map[string][]string{
+		"column 6: expected a value":    {"-where", "age >"},
+		"column 15: expected ),":        {"-q", "where (age > 1"},
+		"unknown output format \"xml\"": {"-out", "xml"},
+		"unknown input format":          {"-in", "yaml"},
+		"-comma must be one character":  {"-comma", ";;"},
+		"no such file":                  {"missing.csv"},
+	}
+	for msg, args := range cases {
+		var out bytes.Buffer
+		err := run(args, strings.NewReader(people), &out)
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%v: expected an error with %q, got %v", args, msg, err)
+		}
+	}
+}
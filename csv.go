@@ -0,0 +1,416 @@
+package stream
+
+import (
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CSVOptions configures FromCSV and ToCSV. The zero value reads and writes
+// comma separated records with a header row.
+type CSVOptions struct {
+	// Comma is the field delimiter, ',' if zero.
+	Comma rune
+	// NoHeader reports the records have no header row, columns are mapped to the fields in declaration order.
+	NoHeader bool
+	// OnError decides what happens to a record whose columns can't be converted to the field types.
+	OnError ErrorPolicy
+}
+
+// csvField is a struct field or a map key mapped to a column.
+type csvField struct {
+	name  string
+	index int
+	key   reflect.Value
+}
+
+// FromCSV create a stream of structs from the CSV records of r. elem is a struct or a pointer to a struct,
+// the stream yields values of the same type. Columns are mapped to exported fields by the `csv:"name"` tag
+// or by the field name, a field tagged `csv:"-"` is ignored. Records are read lazily, so the stream can be
+// consumed only once. opts may be nil.
+//
+// elem may also be a map[string]string or a map[string]interface{}, the records are then maps from the header
+// columns to the texts. In a map[string]interface{} the texts of integers and floats are converted to
+// int64 and float64, unless the conversion would lose their form, as for "007".
+func FromCSV(r io.Reader, elem interface{}, opts *CSVOptions) (*Stream, error) {
+	if opts == nil {
+		opts = &CSVOptions{}
+	}
+	elemType := reflect.TypeOf(elem)
+	if elemType == nil {
+		return nil, errors.New("the type of elem parameter must be Struct, pointer to Struct, map[string]string or map[string]interface{}")
+	}
+
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	if elemType == stringMapType || elemType == anyMapType {
+		return fromCSVMaps(reader, elemType, opts)
+	}
+	if derefType(elemType).Kind() != reflect.Struct {
+		return nil, errors.New("the type of elem parameter must be Struct, pointer to Struct, map[string]string or map[string]interface{}")
+	}
+	structType := derefType(elemType)
+	fields := csvFields(structType)
+	columns := fields
+	if !opts.NoHeader {
+		header, err := reader.Read()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		columns = csvColumns(fields, header)
+	}
+	reader.FieldsPerRecord = -1
+
+	s := &Stream{ops: make([]op, 0), res: elemType}
+	n := 0
+	s.src = &source{next: func() (interface{}, bool, error) {
+		for {
+			n++
+			record, err := reader.Read()
+			if err == io.EOF {
+				return nil, false, nil
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			value := reflect.New(structType)
+			if err := csvDecode(value.Elem(), columns, record); err != nil {
+				err = fmt.Errorf("csv record %d: %w", n, err)
+				if err := s.handle(opts.OnError, err); err != nil {
+					return nil, false, err
+				}
+				continue
+			}
+			if elemType.Kind() == reflect.Ptr {
+				return value.Interface(), true, nil
+			}
+			return value.Elem().Interface(), true, nil
+		}
+	}}
+	return s, nil
+}
+
+var (
+	stringMapType = reflect.TypeOf(map[string]string{})
+	anyMapType    = reflect.TypeOf(map[string]interface{}{})
+)
+
+func fromCSVMaps(reader *csv.Reader, elemType reflect.Type, opts *CSVOptions) (*Stream, error) {
+	if opts.NoHeader {
+		return nil, errors.New("csv: records read as maps need a header row")
+	}
+	header, err := reader.Read()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	reader.FieldsPerRecord = -1
+	s := &Stream{ops: make([]op, 0), res: elemType}
+	s.src = &source{next: func() (interface{}, bool, error) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if elemType == stringMapType {
+			m := make(map[string]string, len(header))
+			for i, text := range record {
+				if i < len(header) {
+					m[header[i]] = text
+				}
+			}
+			return m, true, nil
+		}
+		m := make(map[string]interface{}, len(header))
+		for i, text := range record {
+			if i < len(header) {
+				m[header[i]] = parseCSVValue(text)
+			}
+		}
+		return m, true, nil
+	}}
+	return s, nil
+}
+
+// parseCSVValue converts the text of a number to int64 or float64, if it keeps its form.
+func parseCSVValue(text string) interface{} {
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil && strconv.FormatInt(i, 10) == text {
+		return i
+	}
+	digits := strings.TrimPrefix(text, "-")
+	leadingZero := strings.HasPrefix(digits, "0") && !strings.HasPrefix(digits, "0.")
+	if strings.ContainsAny(text, ".eE") && !leadingZero {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	}
+	return text
+}
+
+// ToCSV operation. Write the elements, which must be structs or pointers to structs, as CSV records to w.
+// The header row is taken from the fields of the first element, or of the type of the elements, as declared by
+// the source and the functions of the operations, if the stream is empty.
+// The elements may also be maps, the header row is then the sorted keys of all of them, and a missing key is
+// written as an empty field. As the header depends on all the maps, they are written once the stream ends.
+// opts may be nil.
+func (s *Stream) ToCSV(w io.Writer, opts *CSVOptions) error {
+	if opts == nil {
+		opts = &CSVOptions{}
+	}
+	writer := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		writer.Comma = opts.Comma
+	}
+	next, done := s.iterator()
+	defer done()
+
+	var fields []csvField
+	var record []string
+	var maps []reflect.Value
+	for it, ok := next(); ok; it, ok = next() {
+		value := reflect.Indirect(reflect.ValueOf(it))
+		if value.Kind() != reflect.Struct && value.Kind() != reflect.Map {
+			return fmt.Errorf("csv: element of type %T is not a struct or a map", it)
+		}
+		isMap := value.Kind() == reflect.Map
+		if isMap && fields != nil || !isMap && maps != nil {
+			return fmt.Errorf("csv: element of type %T mixed with elements of another kind", it)
+		}
+		if isMap {
+			maps = append(maps, value)
+			continue
+		}
+		if fields == nil {
+			fields = csvFields(value.Type())
+			record = make([]string, len(fields))
+			if !opts.NoHeader {
+				for i, f := range fields {
+					record[i] = f.name
+				}
+				if err := writer.Write(record); err != nil {
+					return err
+				}
+			}
+		}
+		for i, f := range fields {
+			record[i] = formatField(value.Field(f.index))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	if maps != nil {
+		if err := writeCSVMaps(writer, maps, opts); err != nil {
+			return err
+		}
+	}
+	if elemType := s.elemType(); fields == nil && maps == nil && !opts.NoHeader && elemType != nil &&
+		derefType(elemType).Kind() == reflect.Struct {
+		for _, f := range csvFields(derefType(elemType)) {
+			record = append(record, f.name)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if s.Err() != nil {
+		return s.Err()
+	}
+	return writer.Error()
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+func csvFields(t reflect.Type) []csvField {
+	fields := make([]csvField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("csv"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, csvField{name: name, index: i})
+	}
+	return fields
+}
+
+// mapFields maps the keys of all the maps to columns, in ascending order.
+func mapFields(maps []reflect.Value) []csvField {
+	seen := make(map[interface{}]bool)
+	keys := make([]reflect.Value, 0)
+	for _, m := range maps {
+		for _, key := range m.MapKeys() {
+			if !seen[key.Interface()] {
+				seen[key.Interface()] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return lessValue(keys[i], keys[j])
+	})
+	fields := make([]csvField, len(keys))
+	for i, key := range keys {
+		fields[i] = csvField{name: fmt.Sprint(key.Interface()), index: -1, key: key}
+	}
+	return fields
+}
+
+// writeCSVMaps writes the maps as records, the columns are the keys of all of them.
+func writeCSVMaps(writer *csv.Writer, maps []reflect.Value, opts *CSVOptions) error {
+	fields := mapFields(maps)
+	record := make([]string, len(fields))
+	if !opts.NoHeader {
+		for i, f := range fields {
+			record[i] = f.name
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	for _, m := range maps {
+		for i, f := range fields {
+			record[i] = ""
+			if f.key.Type().AssignableTo(m.Type().Key()) {
+				record[i] = formatField(m.MapIndex(f.key))
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvColumns maps the header columns to fields, unknown columns are mapped to a field with a negative index.
+func csvColumns(fields []csvField, header []string) []csvField {
+	columns := make([]csvField, len(header))
+	for i, name := range header {
+		columns[i] = csvField{name: name, index: -1}
+		for _, f := range fields {
+			if f.name == name {
+				columns[i] = f
+				break
+			}
+			if strings.EqualFold(f.name, name) {
+				columns[i] = f
+			}
+		}
+	}
+	return columns
+}
+
+func csvDecode(value reflect.Value, columns []csvField, record []string) error {
+	for i, text := range record {
+		if i >= len(columns) || columns[i].index < 0 {
+			continue
+		}
+		if err := parseField(value.Field(columns[i].index), text); err != nil {
+			return fmt.Errorf("field %s: %w", columns[i].name, err)
+		}
+	}
+	return nil
+}
+
+// parseField sets the field from its text form.
+func parseField(field reflect.Value, text string) error {
+	if field.Kind() == reflect.Ptr {
+		if text == "" {
+			return nil
+		}
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(text))
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// formatField returns the text form of the field, empty for a missing or nil value.
+func formatField(field reflect.Value) string {
+	if field.Kind() == reflect.Interface {
+		field = field.Elem()
+	}
+	if !field.IsValid() {
+		return ""
+	}
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return ""
+		}
+		field = field.Elem()
+	}
+	if m, ok := field.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+	switch field.Kind() {
+	case reflect.String:
+		return field.String()
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(field.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(field.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(field.Float(), 'g', -1, field.Type().Bits())
+	}
+	return fmt.Sprint(field.Interface())
+}
@@ -0,0 +1,166 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type person struct {
+	Name  string `csv:"name"`
+	Age   int    `csv:"age"`
+	Email *string
+	note  string
+	Skip  string `csv:"-"`
+}
+
+const peopleCSV = `name,age,Email,extra
+Tom,21,tom@example.com,x
+Kate,19,,y
+Lucy,23,lucy@example.com,z
+`
+
+func TestFromCSV(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	stream, err := FromCSV(strings.NewReader(peopleCSV), person{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var people []person
+	err = stream.Filter(func(p person) bool {
+		return p.Age > 20
+	}).ToSlice(&people)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 2 || people[0].Name != "Tom" || *people[1].Email != "lucy@example.com" {
+		t.Errorf("unexpected people: %v", people)
+	}
+	fmt.Printf("\t%v\n", people)
+}
+
+func TestFromCSVPointer(t *testing.T) {
+	stream, _ := FromCSV(strings.NewReader("Kate;19\nJim;22\n"), &person{}, &CSVOptions{Comma: ';', NoHeader: true})
+	var people []*person
+	stream.ToSlice(&people)
+	if len(people) != 2 || people[1].Name != "Jim" || people[1].Age != 22 || people[1].Email != nil {
+		t.Errorf("unexpected people: %v", people)
+	}
+}
+
+func TestFromCSVErrorPolicy(t *testing.T) {
+	data := "name,age\nTom,21\nKate,old\nLucy,23\n"
+
+	stream, _ := FromCSV(strings.NewReader(data), person{}, nil)
+	if n := stream.Count(); n != 1 || stream.Err() == nil {
+		t.Errorf("fail: count %d, err %v", n, stream.Err())
+	}
+
+	stream, _ = FromCSV(strings.NewReader(data), person{}, &CSVOptions{OnError: ErrorSkip})
+	if n := stream.Count(); n != 2 || stream.Err() != nil || len(stream.Errors()) != 0 {
+		t.Errorf("skip: count %d, err %v, errors %v", n, stream.Err(), stream.Errors())
+	}
+
+	stream, _ = FromCSV(strings.NewReader(data), person{}, &CSVOptions{OnError: ErrorCollect})
+	if n := stream.Count(); n != 2 || stream.Err() != nil || len(stream.Errors()) != 1 {
+		t.Errorf("collect: count %d, err %v, errors %v", n, stream.Err(), stream.Errors())
+	}
+	fmt.Println(t.Name()+":", stream.Errors())
+}
+
+func TestFromCSVErr(t *testing.T) {
+	_, err := FromCSV(strings.NewReader(peopleCSV), 1, nil)
+	fmt.Println(err)
+}
+
+func TestToCSV(t *testing.T) {
+	email := "tom@example.com"
+	stream, _ := Of(person{Name: "Tom", Age: 21, Email: &email}, person{Name: "Kate", Age: 19})
+
+	var buf bytes.Buffer
+	if err := stream.ToCSV(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := "name,age,Email\nTom,21,tom@example.com\nKate,19,\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	stream, _ = FromCSV(strings.NewReader(buf.String()), person{}, nil)
+	buf.Reset()
+	stream.Filter(func(p person) bool {
+		return p.Age > 30
+	}).ToCSV(&buf, &CSVOptions{Comma: '\t'})
+	if buf.String() != "name\tage\tEmail\n" {
+		t.Errorf("unexpected header %q", buf.String())
+	}
+
+	type contact struct {
+		Mail string `csv:"mail"`
+	}
+	stream, _ = FromCSV(strings.NewReader(peopleCSV), person{}, nil)
+	buf.Reset()
+	stream.Filter(func(p person) bool {
+		return p.Age > 30
+	}).Map(func(p person) contact {
+		return contact{}
+	}).ToCSV(&buf, nil)
+	if buf.String() != "mail\n" {
+		t.Errorf("expected the header of the mapped type, got %q", buf.String())
+	}
+}
+
+func TestCSVMaps(t *testing.T) {
+	data := "name,age,zip,score\nTom,21,007,9.5\nKate,19,75001,\n"
+	stream, err := FromCSV(strings.NewReader(data), map[string]interface{}{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]interface{}
+	stream.ToSlice(&rows)
+	if len(rows) != 2 || rows[0]["age"] != int64(21) || rows[0]["zip"] != "007" || rows[0]["score"] != 9.5 ||
+		rows[1]["zip"] != int64(75001) || rows[1]["score"] != "" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+
+	var buf bytes.Buffer
+	stream, _ = New(rows)
+	if err := stream.ToCSV(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := "age,name,score,zip\n21,Tom,9.5,007\n19,Kate,,75001\n"
+	if buf.String() != expected {
+		t.Errorf("expected\n%s, got\n%s", expected, buf.String())
+	}
+
+	stream, _ = FromCSV(strings.NewReader(data), map[string]string{}, nil)
+	var texts []map[string]string
+	stream.ToSlice(&texts)
+	if len(texts) != 2 || texts[0]["age"] != "21" {
+		t.Errorf("unexpected rows %v", texts)
+	}
+	if _, err := FromCSV(strings.NewReader(data), map[string]string{}, &CSVOptions{NoHeader: true}); err == nil {
+		t.Errorf("expected an error for maps without header")
+	}
+	fmt.Println(t.Name()+":", rows)
+}
+
+func TestToCSVMapColumns(t *testing.T) {
+	var buf bytes.Buffer
+	stream, _ := Of(map[string]int{"a": 1}, map[string]int{"a": 2, "b": 3}, map[string]string{"c": "x"})
+	if err := stream.ToCSV(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "a,b,c\n1,,\n2,3,\n,,x\n"; buf.String() != expected {
+		t.Errorf("expected\n%s, got\n%s", expected, buf.String())
+	}
+
+	stream, _ = Of(map[string]int{"a": 1}, struct{ A int }{2})
+	if err := stream.ToCSV(&buf, nil); err == nil || !strings.Contains(err.Error(), "mixed") {
+		t.Errorf("expected an error for maps mixed with structs, got %v", err)
+	}
+	if _, err := FromCSV(strings.NewReader("a\n1\n"), 1, nil); err == nil || !strings.Contains(err.Error(), "map[string]string") {
+		t.Errorf("expected the error to list the accepted types, got %v", err)
+	}
+}
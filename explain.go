@@ -0,0 +1,157 @@
+package stream
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// barrierOps are the operations that read all their input before emitting an element.
+var barrierOps = map[string]bool{
+	"sort":             true,
+	"distinct":         true,
+	"call":             true,
+	"check":            true,
+	"sample":           true,
+	"shuffle":          true,
+	"stratifiedSample": true,
+	"cache":            true,
+}
+
+// Plan describes what a stream will do when it is consumed, see Explain.
+type Plan struct {
+	// Source describes where the elements come from.
+	Source string
+	// Type is the type of the elements of the source, nil if it isn't known.
+	Type reflect.Type
+	// Steps are the operations, in the order they are applied.
+	Steps []PlanStep
+	// Rewrites are the changes Optimize made to the operations.
+	Rewrites []string
+}
+
+// PlanStep describes an operation of a Plan.
+type PlanStep struct {
+	// Kind is the kind of the operation, such as "filter" or "map".
+	Kind string
+	// Func is the name of the function of the operation, empty if it has none.
+	Func string
+	// Arg describes the other arguments of the operation, such as the number of elements of a limit.
+	Arg string
+	// In and Out are the types of the elements before and after the operation, nil if they aren't known.
+	In, Out reflect.Type
+	// Index is true if the function also takes the index of the element.
+	Index bool
+	// Barrier is true if the operation reads all its input before emitting an element.
+	Barrier bool
+}
+
+// Explain returns the plan of the stream, it doesn't consume it.
+func (s *Stream) Explain() Plan {
+	plan := Plan{Type: s.res, Steps: make([]PlanStep, 0, len(s.ops)), Rewrites: s.rewrites}
+	switch {
+	case s.src != nil:
+		plan.Source = "lazy source"
+	default:
+		plan.Source = fmt.Sprintf("slice of %d elements", len(s.data))
+	}
+	t := s.res
+	for _, op := range s.ops {
+		step := PlanStep{Kind: op.typ, Arg: op.describeArg(), In: t, Out: op.outType(t),
+			Index: op.idx, Barrier: barrierOps[op.typ]}
+		if f, ok := op.arg.(fused); ok {
+			step.Func = f.String()
+		} else if op.fun.IsValid() && op.typ != "limit" && op.typ != "skip" {
+			step.Func = funcName(op.fun)
+		}
+		plan.Steps = append(plan.Steps, step)
+		t = step.Out
+	}
+	return plan
+}
+
+// String renders the plan one operation per line.
+func (p Plan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "source: %s of %s\n", p.Source, typeName(p.Type))
+	for i, step := range p.Steps {
+		fmt.Fprintf(&b, "%d. %s", i+1, step.Kind)
+		args := make([]string, 0, 2)
+		if step.Func != "" {
+			args = append(args, step.Func)
+		}
+		if step.Arg != "" {
+			args = append(args, step.Arg)
+		}
+		if len(args) > 0 {
+			fmt.Fprintf(&b, "(%s)", strings.Join(args, ", "))
+		}
+		fmt.Fprintf(&b, " %s -> %s", typeName(step.In), typeName(step.Out))
+		if step.Index {
+			b.WriteString(" [index]")
+		}
+		if step.Barrier {
+			b.WriteString(" [barrier]")
+		}
+		b.WriteString("\n")
+	}
+	for _, rewrite := range p.Rewrites {
+		fmt.Fprintf(&b, "rewrite: %s\n", rewrite)
+	}
+	return b.String()
+}
+
+// describeArg formats the arguments of the operation other than its function.
+func (op op) describeArg() string {
+	switch arg := op.arg.(type) {
+	case sampleArg:
+		switch op.typ {
+		case "sample", "stratifiedSample":
+			return fmt.Sprintf("n=%d", arg.n)
+		case "sampleFraction":
+			return fmt.Sprintf("p=%v", arg.p)
+		}
+	case rateArg:
+		if op.typ == "rateLimit" {
+			return fmt.Sprintf("%d per %v", arg.n, arg.per)
+		}
+		return arg.per.String()
+	case batchArg:
+		return fmt.Sprintf("size=%d, wait=%v", arg.size, arg.wait)
+	case asyncArg:
+		if arg.unordered {
+			return fmt.Sprintf("concurrency=%d, unordered", arg.concurrency)
+		}
+		return fmt.Sprintf("concurrency=%d", arg.concurrency)
+	case RetryPolicy:
+		return fmt.Sprintf("attempts=%d, backoff=%v", arg.MaxAttempts, arg.Backoff)
+	case bloomArg:
+		return fmt.Sprintf("n=%d, p=%v", arg.n, arg.p)
+	case scanArg:
+		if arg.hasInit {
+			return fmt.Sprintf("init=%v", arg.init)
+		}
+	}
+	if op.typ == "limit" || op.typ == "skip" {
+		return fmt.Sprint(call(op.fun)[0].Interface())
+	}
+	return ""
+}
+
+// funcName returns the name of a function without the path of its package.
+func funcName(fun reflect.Value) string {
+	f := runtime.FuncForPC(fun.Pointer())
+	if f == nil {
+		return "?"
+	}
+	name := f.Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+func typeName(t reflect.Type) string {
+	if t == nil {
+		return "?"
+	}
+	return t.String()
+}
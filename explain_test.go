@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func isEven(i int) bool { return i%2 == 0 }
+
+func TestExplain(t *testing.T) {
+	stream, _ := New([]int{3, 1, 2, 4})
+	stream.Filter(isEven).MapIndex(func(n, i int) string {
+		return fmt.Sprint(i, ":", n)
+	}).Sort(func(a, b string) bool { return a < b }).Limit(1)
+
+	plan := stream.Explain()
+	fmt.Print(t.Name()+":\n", plan)
+	if plan.Source != "slice of 4 elements" || plan.Type != reflect.TypeOf(0) || len(plan.Steps) != 4 {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	filter, mapIndex, sort, limit := plan.Steps[0], plan.Steps[1], plan.Steps[2], plan.Steps[3]
+	if filter.Kind != "filter" || filter.Func != "stream.isEven" || filter.Barrier {
+		t.Errorf("unexpected filter step %+v", filter)
+	}
+	if !mapIndex.Index || mapIndex.In != reflect.TypeOf(0) || mapIndex.Out != reflect.TypeOf("") {
+		t.Errorf("unexpected map step %+v", mapIndex)
+	}
+	if !sort.Barrier || sort.In != reflect.TypeOf("") {
+		t.Errorf("unexpected sort step %+v", sort)
+	}
+	if limit.Arg != "1" || limit.Func != "" {
+		t.Errorf("unexpected limit step %+v", limit)
+	}
+	if !strings.Contains(plan.String(), "1. filter(stream.isEven) int -> int\n") {
+		t.Errorf("unexpected rendering\n%s", plan)
+	}
+
+	var result []string
+	stream.ToSlice(&result)
+	if fmt.Sprint(result) != "[0:2]" {
+		t.Errorf("explain must not consume the stream, got %v", result)
+	}
+}
+
+func TestExplainSource(t *testing.T) {
+	stream, _ := FromChan(make(chan int))
+	plan := stream.MapAsync(func(i int) int { return i }, 4).Batch(10, 0).Explain()
+	if plan.Source != "lazy source" {
+		t.Errorf("unexpected source %q", plan.Source)
+	}
+	if plan.Steps[0].Arg != "concurrency=4" || plan.Steps[1].Out != reflect.TypeOf([]int{}) {
+		t.Errorf("unexpected steps %+v", plan.Steps)
+	}
+}
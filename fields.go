@@ -0,0 +1,536 @@
+package stream
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// accessors caches the compiled field paths by element type and path.
+var accessors sync.Map
+
+type accessorKey struct {
+	typ  reflect.Type
+	path string
+	fold bool
+}
+
+// accessor reads the value at a field path. The steps are checked against the type the path is compiled for;
+// after an interface the rest of the path is compiled for the dynamic type of the value.
+type accessor struct {
+	steps []pathStep
+	out   reflect.Type
+	rest  string
+	fold  bool
+}
+
+type stepKind int
+
+const (
+	fieldStep stepKind = iota
+	keyStep
+	indexStep
+)
+
+type pathStep struct {
+	kind  stepKind
+	field []int
+	key   reflect.Value
+	index int
+}
+
+// pathSegment is a name or a bracketed key of a field path, pos is its offset in the path.
+type pathSegment struct {
+	name    string
+	bracket bool
+	pos     int
+}
+
+// splitPath splits a path such as "Dept.Members[0].Name" or "Labels[env]" into its segments.
+func splitPath(path string) ([]pathSegment, error) {
+	segments := make([]pathSegment, 0)
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("stream: invalid field path %q: unclosed [ at %d", path, i)
+			}
+			key := strings.Trim(path[i+1:i+end], `"`)
+			segments = append(segments, pathSegment{name: key, bracket: true, pos: i})
+			i += end + 1
+		case '.':
+			if i == 0 || i == len(path)-1 || path[i+1] == '.' || path[i+1] == '[' {
+				return nil, fmt.Errorf("stream: invalid field path %q: unexpected . at %d", path, i)
+			}
+			i++
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			if i > 0 && path[i-1] != '.' {
+				return nil, fmt.Errorf("stream: invalid field path %q: expected . at %d", path, i)
+			}
+			segments = append(segments, pathSegment{name: path[i : i+end], pos: i})
+			i += end
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("stream: empty field path")
+	}
+	return segments, nil
+}
+
+// lookupAccessor returns the accessor of path for values of type t, compiling it once. If fold is true, a struct
+// field is matched case-insensitively when no field has the exact name.
+func lookupAccessor(t reflect.Type, path string, fold bool) (*accessor, error) {
+	key := accessorKey{typ: t, path: path, fold: fold}
+	if a, ok := accessors.Load(key); ok {
+		return a.(*accessor), nil
+	}
+	a, err := compileAccessor(t, path, fold)
+	if err != nil {
+		return nil, err
+	}
+	accessors.Store(key, a)
+	return a, nil
+}
+
+func compileAccessor(t reflect.Type, path string, fold bool) (*accessor, error) {
+	segments, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &accessor{steps: make([]pathStep, 0, len(segments)), fold: fold}
+	cur := t
+	for _, seg := range segments {
+		for cur.Kind() == reflect.Ptr {
+			cur = cur.Elem()
+		}
+		switch cur.Kind() {
+		case reflect.Interface:
+			a.rest = strings.TrimPrefix(path[seg.pos:], ".")
+			a.out = cur
+			return a, nil
+		case reflect.Struct:
+			f, ok := cur.FieldByName(seg.name)
+			if !ok && fold {
+				f, ok = cur.FieldByNameFunc(func(name string) bool {
+					return strings.EqualFold(name, seg.name) && token.IsExported(name)
+				})
+			}
+			if seg.bracket || !ok {
+				return nil, fmt.Errorf("stream: field path %q: %s has no field %s", path, cur, seg.name)
+			}
+			if f.PkgPath != "" {
+				return nil, fmt.Errorf("stream: field path %q: field %s of %s is not exported", path, seg.name, cur)
+			}
+			a.steps = append(a.steps, pathStep{kind: fieldStep, field: f.Index})
+			cur = f.Type
+		case reflect.Map:
+			key, err := parseKey(seg.name, cur.Key())
+			if err != nil {
+				return nil, fmt.Errorf("stream: field path %q: %v", path, err)
+			}
+			a.steps = append(a.steps, pathStep{kind: keyStep, key: key})
+			cur = cur.Elem()
+		case reflect.Slice, reflect.Array:
+			index, err := strconv.Atoi(seg.name)
+			if !seg.bracket || err != nil || index < 0 {
+				return nil, fmt.Errorf("stream: field path %q: %s is not an index of %s", path, seg.name, cur)
+			}
+			a.steps = append(a.steps, pathStep{kind: indexStep, index: index})
+			cur = cur.Elem()
+		default:
+			return nil, fmt.Errorf("stream: field path %q: %s has no field %s", path, cur, seg.name)
+		}
+	}
+	a.out = cur
+	return a, nil
+}
+
+// parseKey converts the text of a map key to the key type.
+func parseKey(text string, t reflect.Type) (reflect.Value, error) {
+	key := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		key.SetString(text)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, t.Bits())
+		if err != nil {
+			return key, fmt.Errorf("%s is not a key of type %s", text, t)
+		}
+		key.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, t.Bits())
+		if err != nil {
+			return key, fmt.Errorf("%s is not a key of type %s", text, t)
+		}
+		key.SetUint(u)
+	case reflect.Interface:
+		key.Set(reflect.ValueOf(text))
+	default:
+		return key, fmt.Errorf("keys of type %s aren't supported", t)
+	}
+	return key, nil
+}
+
+// get returns the value at the path, an invalid value if a pointer on the way is nil, or a key or index is missing.
+func (a *accessor) get(v reflect.Value) (reflect.Value, error) {
+	for _, step := range a.steps {
+		if !v.IsValid() {
+			return v, nil
+		}
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, nil
+			}
+			v = v.Elem()
+		}
+		switch step.kind {
+		case fieldStep:
+			f, err := v.FieldByIndexErr(step.field)
+			if err != nil {
+				return reflect.Value{}, nil
+			}
+			v = f
+		case keyStep:
+			v = v.MapIndex(step.key)
+		case indexStep:
+			if step.index >= v.Len() {
+				return reflect.Value{}, nil
+			}
+			v = v.Index(step.index)
+		}
+	}
+	if a.rest == "" || !v.IsValid() {
+		return v, nil
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, nil
+		}
+		v = v.Elem()
+	}
+	b, err := lookupAccessor(v.Type(), a.rest, a.fold)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return b.get(v)
+}
+
+// field returns a function reading the value at path of an element, invalid if it is missing, and the type of
+// the value, nil if it is only known at run time. The path is checked now if the element type is known,
+// otherwise a bad path fails the stream when it is read. fold is that of lookupAccessor.
+func (s *Stream) field(path string, fold bool) (func(it interface{}) reflect.Value, reflect.Type, error) {
+	t := s.elemType()
+	if t != nil && t.Kind() != reflect.Interface {
+		a, err := lookupAccessor(t, path, fold)
+		if err != nil {
+			return nil, nil, err
+		}
+		out := a.out
+		if a.rest != "" {
+			out = nil
+		}
+		return func(it interface{}) reflect.Value {
+			v, err := a.get(reflect.ValueOf(it))
+			if err != nil {
+				s.fail(err)
+			}
+			return v
+		}, out, nil
+	}
+	if _, err := splitPath(path); err != nil {
+		return nil, nil, err
+	}
+	return func(it interface{}) reflect.Value {
+		if it == nil {
+			return reflect.Value{}
+		}
+		a, err := lookupAccessor(reflect.TypeOf(it), path, fold)
+		if err == nil {
+			var v reflect.Value
+			if v, err = a.get(reflect.ValueOf(it)); err == nil {
+				return v
+			}
+		}
+		s.fail(err)
+		return reflect.Value{}
+	}, nil, nil
+}
+
+// elemFuncType returns the type of a function taking the elements, and returning the types out.
+func (s *Stream) elemFuncType(out ...reflect.Type) reflect.Type {
+	in := s.elemType()
+	if in == nil {
+		in = anyType
+	}
+	return reflect.FuncOf([]reflect.Type{in}, out, false)
+}
+
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// Pluck operation. Map the elements to the value at a field path, such as "Dept.Name", "Tags[0]" or
+// "Labels[env]". Pointers on the way are followed, a nil one or a missing key or index gives the zero value.
+func (s *Stream) Pluck(path string) *Stream {
+	get, typ, err := s.field(path, false)
+	if err != nil {
+		s.failOp(err)
+		return s
+	}
+	if typ == nil {
+		typ = anyType
+	}
+	fn := reflect.MakeFunc(s.elemFuncType(typ), func(args []reflect.Value) []reflect.Value {
+		v := get(args[0].Interface())
+		if !v.IsValid() {
+			return []reflect.Value{reflect.Zero(typ)}
+		}
+		return []reflect.Value{v.Convert(typ)}
+	})
+	return s.Map(fn.Interface())
+}
+
+// SortByField operation. Sort the elements in ascending order of the value at a field path, see Pluck.
+// Numbers, strings and bools are compared by value, missing values come first.
+func (s *Stream) SortByField(path string) *Stream {
+	return s.sortByField(path, false)
+}
+
+// SortByFieldDesc operation. Sort the elements in descending order of the value at a field path, see SortByField.
+func (s *Stream) SortByFieldDesc(path string) *Stream {
+	return s.sortByField(path, true)
+}
+
+func (s *Stream) sortByField(path string, desc bool) *Stream {
+	get, _, err := s.field(path, false)
+	if err != nil {
+		s.failOp(err)
+		return s
+	}
+	in := s.elemFuncType().In(0)
+	typ := reflect.FuncOf([]reflect.Type{in, in}, []reflect.Type{reflect.TypeOf(true)}, false)
+	fn := reflect.MakeFunc(typ, func(args []reflect.Value) []reflect.Value {
+		a, b := get(args[0].Interface()), get(args[1].Interface())
+		if desc {
+			a, b = b, a
+		}
+		return []reflect.Value{reflect.ValueOf(lessField(a, b))}
+	})
+	return s.Sort(fn.Interface())
+}
+
+// lessField compares two field values, an invalid value is less than any other.
+func lessField(a, b reflect.Value) bool {
+	a, b = indirectValue(a), indirectValue(b)
+	if !a.IsValid() || !b.IsValid() {
+		return !a.IsValid() && b.IsValid()
+	}
+	if c, err := compareValues(a, b); err == nil {
+		return c < 0
+	}
+	return lessValue(a, b)
+}
+
+// indirectValue follows pointers and interfaces, a nil one gives an invalid value.
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// GroupByField operation. Group the elements by the value at a field path, see Pluck.
+// The elements whose value is missing are grouped under nil. A value that can't be a map key, such as a slice,
+// fails the stream and the result is nil.
+func (s *Stream) GroupByField(path string) map[interface{}][]interface{} {
+	get, _, err := s.field(path, false)
+	if err != nil {
+		s.fail(err)
+		return nil
+	}
+	result := make(map[interface{}][]interface{})
+	for _, it := range s.collect() {
+		var key interface{}
+		if v := indirectValue(get(it)); v.IsValid() {
+			if !v.Comparable() {
+				s.fail(fmt.Errorf("stream: group by %s: value of type %s can't be a map key", path, v.Type()))
+				return nil
+			}
+			key = v.Interface()
+		}
+		result[key] = append(result[key], it)
+	}
+	return result
+}
+
+// FilterField operation. Keep the elements whose value at a field path, see Pluck, compares to value with operator:
+// "==", "!=", "<", "<=", ">" or ">=", or matches the regular expression value with "~".
+// The elements whose value is missing are dropped. The operator and the type of value are checked against
+// the type of the field when it is known.
+func (s *Stream) FilterField(path string, operator string, value interface{}) *Stream {
+	get, typ, err := s.field(path, false)
+	if err == nil {
+		var match func(v reflect.Value) (bool, error)
+		if match, err = fieldMatcher(typ, operator, value); err == nil {
+			fn := reflect.MakeFunc(s.elemFuncType(reflect.TypeOf(true)), func(args []reflect.Value) []reflect.Value {
+				ok, err := match(get(args[0].Interface()))
+				if err != nil {
+					s.fail(err)
+				}
+				return []reflect.Value{reflect.ValueOf(ok)}
+			})
+			return s.Filter(fn.Interface())
+		}
+	}
+	s.failOp(fmt.Errorf("stream: filter on %s: %w", path, err))
+	return s
+}
+
+// fieldMatcher returns a function comparing a field value of type typ, nil if unknown, to value with operator.
+func fieldMatcher(typ reflect.Type, operator string, value interface{}) (func(v reflect.Value) (bool, error), error) {
+	want := reflect.ValueOf(value)
+	if !want.IsValid() {
+		return nil, fmt.Errorf("can't compare with nil")
+	}
+	if operator == "~" {
+		pattern, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("the pattern of ~ must be a string, not %T", value)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if typ != nil && derefType(typ).Kind() != reflect.String && derefType(typ).Kind() != reflect.Interface {
+			return nil, fmt.Errorf("~ needs a string, not %s", typ)
+		}
+		return func(v reflect.Value) (bool, error) {
+			v = indirectValue(v)
+			if !v.IsValid() {
+				return false, nil
+			}
+			if v.Kind() != reflect.String {
+				return false, fmt.Errorf("~ needs a string, not %s", v.Type())
+			}
+			return re.MatchString(v.String()), nil
+		}, nil
+	}
+	var test func(c int) bool
+	switch operator {
+	case "==":
+		test = func(c int) bool { return c == 0 }
+	case "!=":
+		test = func(c int) bool { return c != 0 }
+	case "<":
+		test = func(c int) bool { return c < 0 }
+	case "<=":
+		test = func(c int) bool { return c <= 0 }
+	case ">":
+		test = func(c int) bool { return c > 0 }
+	case ">=":
+		test = func(c int) bool { return c >= 0 }
+	default:
+		return nil, fmt.Errorf("unknown operator %q", operator)
+	}
+	ordered := operator != "==" && operator != "!="
+	if typ != nil && derefType(typ).Kind() != reflect.Interface {
+		if _, err := compareValues(reflect.Zero(derefType(typ)), want); err != nil {
+			return nil, err
+		}
+		if ordered && derefType(typ).Kind() == reflect.Bool {
+			return nil, fmt.Errorf("%s can't compare bools", operator)
+		}
+	}
+	return func(v reflect.Value) (bool, error) {
+		v = indirectValue(v)
+		if !v.IsValid() {
+			return false, nil
+		}
+		c, err := compareValues(v, want)
+		if err != nil {
+			return false, err
+		}
+		return test(c), nil
+	}, nil
+}
+
+// compareValues compares two numbers, strings or bools, it returns -1, 0 or 1 as a is less, equal or greater.
+func compareValues(a, b reflect.Value) (int, error) {
+	switch {
+	case isNumber(a.Type()) && isNumber(b.Type()):
+		switch {
+		case isInt(a) && isInt(b):
+			return compareInts(a.Int(), b.Int()), nil
+		case isUint(a) && isUint(b):
+			return compareUints(a.Uint(), b.Uint()), nil
+		case isInt(a) && isUint(b):
+			if a.Int() < 0 {
+				return -1, nil
+			}
+			return compareUints(uint64(a.Int()), b.Uint()), nil
+		case isUint(a) && isInt(b):
+			if b.Int() < 0 {
+				return 1, nil
+			}
+			return compareUints(a.Uint(), uint64(b.Int())), nil
+		}
+		x, _ := toFloat(a.Interface())
+		y, _ := toFloat(b.Interface())
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return strings.Compare(a.String(), b.String()), nil
+	case a.Kind() == reflect.Bool && b.Kind() == reflect.Bool:
+		if a.Bool() == b.Bool() {
+			return 0, nil
+		}
+		if b.Bool() {
+			return -1, nil
+		}
+		return 1, nil
+	}
+	return 0, fmt.Errorf("can't compare %s with %s", a.Type(), b.Type())
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareUints(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,176 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type dept struct {
+	Name string
+}
+
+type employee struct {
+	Name   string
+	Age    int
+	Dept   *dept
+	Tags   []string
+	Labels map[string]string
+	Extra  interface{}
+	salary int
+}
+
+func createEmployees() []employee {
+	sales, dev := &dept{Name: "sales"}, &dept{Name: "dev"}
+	return []employee{
+		{Name: "Kate", Age: 31, Dept: dev, Tags: []string{"go", "sql"}, Labels: map[string]string{"site": "paris"}},
+		{Name: "Tom", Age: 22, Dept: sales, Tags: []string{"excel"}},
+		{Name: "King", Age: 45, Dept: dev, Labels: map[string]string{"site": "berlin"}, Extra: dept{Name: "ops"}},
+		{Name: "Lucy", Age: 27},
+	}
+}
+
+func TestPluck(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	for _, path := range []string{"Name", "Dept.Name", "Tags[0]", "Labels[site]", "Labels.site", "Extra.Name"} {
+		stream, _ := New(createEmployees())
+		var result []interface{}
+		if err := stream.Pluck(path).ToSlice(&result); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+		fmt.Printf("\t%s: %v\n", path, result)
+	}
+
+	stream, _ := New(createEmployees())
+	var tags []string
+	stream.Pluck("Tags[1]").ToSlice(&tags)
+	if fmt.Sprint(tags) != "[sql   ]" {
+		t.Errorf("expected zero values for missing indices, got %q", tags)
+	}
+	stream, _ = New(createEmployees())
+	if sum := stream.Pluck("Age").Sum(); sum != 125 {
+		t.Errorf("expected the plucked ints to sum to 125, got %v", sum)
+	}
+}
+
+func TestFieldPathErrors(t *testing.T) {
+	for path, msg := range map[string]string{
+		"Salary":      "has no field Salary",
+		"name":        "has no field name",
+		"salary":      "is not exported",
+		"Dept.Name.X": "string has no field X",
+		"Tags.first":  "is not an index",
+		"Name..Age":   "unexpected . at 4",
+		"Tags[0":      "unclosed [",
+	} {
+		stream, _ := New(createEmployees())
+		err := stream.Pluck(path).ToSlice(&[]interface{}{})
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: expected an error with %q, got %v", path, msg, err)
+		}
+	}
+
+	// without a known element type the path is checked on each element
+	stream, _ := Of(employee{Name: "Kate"}, dept{Name: "dev"})
+	var names []interface{}
+	err := stream.Pluck("Age").ToSlice(&names)
+	if err == nil || !strings.Contains(err.Error(), "stream.dept has no field Age") {
+		t.Errorf("expected an error on the dept, got %v", err)
+	}
+}
+
+func TestSortByField(t *testing.T) {
+	stream, _ := New(createEmployees())
+	var names []string
+	stream.SortByField("Age").Pluck("Name").ToSlice(&names)
+	if fmt.Sprint(names) != "[Tom Lucy Kate King]" {
+		t.Errorf("unexpected order %v", names)
+	}
+
+	stream, _ = New(createEmployees())
+	names = nil
+	stream.SortByFieldDesc("Dept.Name").Pluck("Name").ToSlice(&names)
+	if len(names) != 4 || names[0] != "Tom" || names[3] != "Lucy" {
+		t.Errorf("unexpected order %v", names)
+	}
+	fmt.Println(t.Name()+":", names)
+}
+
+func TestGroupByField(t *testing.T) {
+	stream, _ := New(createEmployees())
+	groups := stream.GroupByField("Dept.Name")
+	if len(groups["dev"]) != 2 || len(groups["sales"]) != 1 || len(groups[nil]) != 1 {
+		t.Errorf("unexpected groups %v", groups)
+	}
+
+	stream.Reset()
+	groups = stream.GroupByField("Tags")
+	if err := stream.Err(); groups != nil || err == nil || !strings.Contains(err.Error(), "can't be a map key") {
+		t.Errorf("expected an error for slice keys, got %v, %v", groups, err)
+	}
+}
+
+func TestFieldErrorReset(t *testing.T) {
+	stream, _ := New(createEmployees())
+	if n := stream.Pluck("Salary").Count(); n != 0 || stream.Err() == nil {
+		t.Errorf("expected the bad path to fail the stream, got %d elements, %v", n, stream.Err())
+	}
+	if n := stream.Reset().Pluck("Name").Count(); n != 4 || stream.Err() != nil {
+		t.Errorf("expected Reset to drop the bad path, got %d elements, %v", n, stream.Err())
+	}
+}
+
+func TestFilterField(t *testing.T) {
+	cases := []struct {
+		path, operator string
+		value          interface{}
+		expected       string
+	}{
+		{"Age", ">", 30, "[Kate King]"},
+		{"Age", "<=", 27.5, "[Tom Lucy]"},
+		{"Name", "~", "^K", "[Kate King]"},
+		{"Dept.Name", "!=", "dev", "[Tom]"},
+		{"Labels[site]", "==", "paris", "[Kate]"},
+	}
+	for _, c := range cases {
+		stream, _ := New(createEmployees())
+		var names []string
+		err := stream.FilterField(c.path, c.operator, c.value).Pluck("Name").ToSlice(&names)
+		if err != nil || fmt.Sprint(names) != c.expected {
+			t.Errorf("%s %s %v: expected %s, got %v, %v", c.path, c.operator, c.value, c.expected, names, err)
+		}
+	}
+
+	for _, c := range []struct {
+		operator string
+		value    interface{}
+	}{{">", "30"}, {"~", 3}, {"=~", 3}} {
+		stream, _ := New(createEmployees())
+		if err := stream.FilterField("Age", c.operator, c.value).ToSlice(&[]employee{}); err == nil {
+			t.Errorf("Age %s %v: expected an error", c.operator, c.value)
+		}
+	}
+
+	type counter struct {
+		N uint64
+		I int64
+	}
+	counters := []counter{{N: 1<<63 + 1, I: -1}, {N: 1 << 63, I: 1}}
+	for _, c := range []struct {
+		path, operator string
+		value          interface{}
+		expected       string
+	}{
+		{"N", ">", uint64(1 << 63), "[{9223372036854775809 -1}]"},
+		{"N", "==", uint64(1<<63 + 1), "[{9223372036854775809 -1}]"},
+		{"N", ">", int64(-1), "[{9223372036854775809 -1} {9223372036854775808 1}]"},
+		{"I", "<", uint64(1), "[{9223372036854775809 -1}]"},
+	} {
+		stream, _ := New(counters)
+		var result []counter
+		err := stream.FilterField(c.path, c.operator, c.value).ToSlice(&result)
+		if err != nil || fmt.Sprint(result) != c.expected {
+			t.Errorf("%s %s %v: expected %s, got %v, %v", c.path, c.operator, c.value, c.expected, result, err)
+		}
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/tk103331/stream
 
-go 1.13
+go 1.21
+
+require go.yaml.in/yaml/v3 v3.0.4
@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// FromJSONLines create a stream from the JSON values of r, such as newline delimited JSON.
+// Each value is decoded into a new value of the type of elem, the stream yields values of the same type.
+// Values are decoded lazily, so the stream can be consumed only once.
+func FromJSONLines(r io.Reader, elem interface{}) (*Stream, error) {
+	elemType := reflect.TypeOf(elem)
+	if elemType == nil {
+		return nil, errors.New("the elem parameter must not be nil")
+	}
+	dec := json.NewDecoder(r)
+	s := &Stream{ops: make([]op, 0), res: elemType}
+	s.src = &source{next: func() (interface{}, bool, error) {
+		if !dec.More() {
+			tok, err := dec.Token()
+			if err == io.EOF {
+				return nil, false, nil
+			}
+			if err == nil {
+				err = fmt.Errorf("json: unexpected %v", tok)
+			}
+			return nil, false, err
+		}
+		return decodeJSON(dec, elemType)
+	}}
+	return s, nil
+}
+
+// FromJSONArray create a stream from the elements of the JSON array read from r.
+// Each element is decoded into a new value of the type of elem, the stream yields values of the same type.
+// Elements are decoded lazily, so the stream can be consumed only once.
+func FromJSONArray(r io.Reader, elem interface{}) (*Stream, error) {
+	elemType := reflect.TypeOf(elem)
+	if elemType == nil {
+		return nil, errors.New("the elem parameter must not be nil")
+	}
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("json: expected array, got %v", tok)
+	}
+	s := &Stream{ops: make([]op, 0), res: elemType}
+	s.src = &source{next: func() (interface{}, bool, error) {
+		if !dec.More() {
+			if _, err := dec.Token(); err != nil {
+				return nil, false, err
+			}
+			return nil, false, nil
+		}
+		return decodeJSON(dec, elemType)
+	}}
+	return s, nil
+}
+
+func decodeJSON(dec *json.Decoder, elemType reflect.Type) (interface{}, bool, error) {
+	value := reflect.New(elemType)
+	if err := dec.Decode(value.Interface()); err != nil {
+		return nil, false, err
+	}
+	return value.Elem().Interface(), true, nil
+}
+
+// ToJSONLines operation. Write the elements to w as newline delimited JSON.
+func (s *Stream) ToJSONLines(w io.Writer) error {
+	next, done := s.iterator()
+	defer done()
+	enc := json.NewEncoder(w)
+	for it, ok := next(); ok; it, ok = next() {
+		if err := enc.Encode(it); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// ToJSONArray operation. Write the elements to w as a JSON array.
+func (s *Stream) ToJSONArray(w io.Writer) error {
+	next, done := s.iterator()
+	defer done()
+	bw := bufio.NewWriter(w)
+	bw.WriteByte('[')
+	first := true
+	for it, ok := next(); ok; it, ok = next() {
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		data, err := json.Marshal(it)
+		if err != nil {
+			return err
+		}
+		bw.Write(data)
+	}
+	bw.WriteString("]\n")
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return s.Err()
+}
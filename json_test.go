@@ -0,0 +1,87 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type event struct {
+	ID    int    `json:"id"`
+	Kind  string `json:"kind"`
+	Value int    `json:"value"`
+}
+
+const eventsNDJSON = `{"id":1,"kind":"click","value":3}
+{"id":2,"kind":"view","value":1}
+{"id":3,"kind":"click","value":5}
+`
+
+func TestFromJSONLines(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	stream, err := FromJSONLines(strings.NewReader(eventsNDJSON), event{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	stream.Filter(func(e event) bool {
+		return e.Kind == "click"
+	}).Map(func(e event) int {
+		return e.ID
+	}).ToSlice(&ids)
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+	fmt.Printf("\t%v\n", ids)
+}
+
+func TestFromJSONLinesErr(t *testing.T) {
+	stream, _ := FromJSONLines(strings.NewReader(eventsNDJSON+"{\"id\":"), &event{})
+	if n := stream.Count(); n != 3 || stream.Err() == nil {
+		t.Errorf("count %d, err %v", n, stream.Err())
+	}
+	fmt.Println(t.Name()+":", stream.Err())
+}
+
+func TestFromJSONArray(t *testing.T) {
+	stream, err := FromJSONArray(strings.NewReader(`[{"id":1,"value":2}, {"id":2,"value":4}]`), &event{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := stream.Reduce(0, func(sum int, e *event) int {
+		return sum + e.Value
+	})
+	if sum != 6 || stream.Err() != nil {
+		t.Errorf("sum %v, err %v", sum, stream.Err())
+	}
+
+	_, err = FromJSONArray(strings.NewReader(`{"id":1}`), event{})
+	fmt.Println(t.Name()+":", err)
+}
+
+func TestToJSON(t *testing.T) {
+	stream, _ := FromJSONLines(strings.NewReader(eventsNDJSON), event{})
+	var buf bytes.Buffer
+	stream.Limit(2).ToJSONLines(&buf)
+	expected := `{"id":1,"kind":"click","value":3}
+{"id":2,"kind":"view","value":1}
+`
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	ints, _ := Ints(1, 2, 3)
+	ints.ToJSONArray(&buf)
+	if buf.String() != "[1,2,3]\n" {
+		t.Errorf("unexpected array %q", buf.String())
+	}
+
+	buf.Reset()
+	empty, _ := Ints()
+	empty.ToJSONArray(&buf)
+	if buf.String() != "[]\n" {
+		t.Errorf("unexpected array %q", buf.String())
+	}
+}
@@ -0,0 +1,172 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Pair is an entry of a map.
+type Pair struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// FromMap create a stream of Pairs from the entries of a map, in unspecified order.
+func FromMap(m interface{}) (*Stream, error) {
+	return fromMap(m, false)
+}
+
+// FromMapSorted create a stream of Pairs from the entries of a map, in ascending order of keys.
+// Numbers and strings are compared by value, other keys by their default format.
+func FromMapSorted(m interface{}) (*Stream, error) {
+	return fromMap(m, true)
+}
+
+func fromMap(m interface{}, sorted bool) (*Stream, error) {
+	mapValue := reflect.Indirect(reflect.ValueOf(m))
+	if mapValue.Kind() != reflect.Map {
+		return nil, errors.New("the type of m parameter must be Map")
+	}
+	keys := mapValue.MapKeys()
+	if sorted {
+		sort.Slice(keys, func(i, j int) bool {
+			return lessValue(keys[i], keys[j])
+		})
+	}
+	data := make([]Pair, len(keys))
+	for i, k := range keys {
+		data[i] = Pair{Key: k.Interface(), Value: mapValue.MapIndex(k).Interface()}
+	}
+	return New(data)
+}
+
+// lessValue compares two values of the same kind in their natural order.
+func lessValue(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface {
+		a, b = a.Elem(), b.Elem()
+	}
+	if a.Kind() == b.Kind() {
+		switch a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Bool:
+			return !a.Bool() && b.Bool()
+		}
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+// Keys operation. Map the Pairs to their keys.
+func (s *Stream) Keys() *Stream {
+	return s.Map(func(p Pair) interface{} {
+		return p.Key
+	})
+}
+
+// Values operation. Map the Pairs to their values.
+func (s *Stream) Values() *Stream {
+	return s.Map(func(p Pair) interface{} {
+		return p.Value
+	})
+}
+
+// MapValues operation. Map the values of the Pairs, keeping their keys.
+// mapFunc: func(v T1) T2
+func (s *Stream) MapValues(mapFunc interface{}) *Stream {
+	funcValue := reflect.ValueOf(mapFunc)
+	return s.Map(func(p Pair) Pair {
+		return Pair{Key: p.Key, Value: call(funcValue, p.Value)[0].Interface()}
+	})
+}
+
+// FilterKeys operation. Filter the Pairs by their keys.
+// filterFunc: func(k T) bool
+func (s *Stream) FilterKeys(filterFunc interface{}) *Stream {
+	funcValue := reflect.ValueOf(filterFunc)
+	return s.Filter(func(p Pair) bool {
+		return call(funcValue, p.Key)[0].Bool()
+	})
+}
+
+// ToMap operation. Put the Pairs into the map targetMap points to, the map is created if it is nil.
+// mergeFunc resolves duplicate keys, func(old, new V) V; if it is nil a duplicate key is an error.
+func (s *Stream) ToMap(targetMap interface{}, mergeFunc interface{}) error {
+	targetValue := reflect.ValueOf(targetMap)
+	if targetValue.Kind() != reflect.Ptr || targetValue.Elem().Kind() != reflect.Map {
+		return errors.New("target map must be a pointer to a map")
+	}
+	mapValue := targetValue.Elem()
+	if mapValue.IsNil() {
+		mapValue.Set(reflect.MakeMap(mapValue.Type()))
+	}
+	keyType, valueType := mapValue.Type().Key(), mapValue.Type().Elem()
+	funcValue := reflect.ValueOf(mergeFunc)
+
+	seen := make(map[interface{}]bool)
+	for _, it := range s.collect() {
+		p, ok := it.(Pair)
+		if !ok {
+			return fmt.Errorf("element of type %T is not a Pair", it)
+		}
+		key, err := convertTo(p.Key, keyType)
+		if err != nil {
+			return fmt.Errorf("key %v: %w", p.Key, err)
+		}
+		value, err := convertTo(p.Value, valueType)
+		if err != nil {
+			return fmt.Errorf("value of key %v: %w", p.Key, err)
+		}
+		if seen[key.Interface()] {
+			if mergeFunc == nil {
+				return fmt.Errorf("duplicate key %v", p.Key)
+			}
+			merged := call(funcValue, mapValue.MapIndex(key).Interface(), p.Value)[0].Interface()
+			if value, err = convertTo(merged, valueType); err != nil {
+				return fmt.Errorf("merged value of key %v: %w", p.Key, err)
+			}
+		}
+		seen[key.Interface()] = true
+		mapValue.SetMapIndex(key, value)
+	}
+	return s.Err()
+}
+
+// convertValue converts x to a value of type t, nil is converted to the zero value.
+func convertValue(x interface{}, t reflect.Type) reflect.Value {
+	if x == nil {
+		return reflect.Zero(t)
+	}
+	return reflect.ValueOf(x).Convert(t)
+}
+
+// convertTo converts x to a value of type t like convertValue, but returns an error rather than panic when x doesn't
+// convert, and rather than turn an integer into the string of a rune.
+func convertTo(x interface{}, t reflect.Type) (reflect.Value, error) {
+	if x == nil {
+		return reflect.Zero(t), nil
+	}
+	v := reflect.ValueOf(x)
+	if v.Type().AssignableTo(t) {
+		return v.Convert(t), nil
+	}
+	if !v.Type().ConvertibleTo(t) || t.Kind() == reflect.String && (isInt(v) || isUint(v)) {
+		return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", x, t)
+	}
+	return v.Convert(t), nil
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFromMap(t *testing.T) {
+	stream, err := FromMap(map[string]int{"a": 1, "b": 2, "c": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := stream.Count(); n != 3 {
+		t.Errorf("expected 3 pairs, got %d", n)
+	}
+
+	_, err = FromMap([]int{1})
+	fmt.Println(t.Name()+":", err)
+}
+
+func TestFromMapSorted(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	stream, _ := FromMapSorted(map[int]string{3: "c", 1: "a", 2: "b", 10: "j"})
+	var keys []int
+	stream.Keys().ToSlice(&keys)
+	if fmt.Sprint(keys) != "[1 2 3 10]" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	stream.Reset()
+	var values []string
+	stream.Values().ToSlice(&values)
+	if fmt.Sprint(values) != "[a b c j]" {
+		t.Errorf("unexpected values %v", values)
+	}
+	fmt.Printf("\t%v %v\n", keys, values)
+}
+
+func TestMapValuesFilterKeys(t *testing.T) {
+	stream, _ := FromMap(map[string]int{"apple": 1, "avocado": 2, "banana": 3})
+	result := make(map[string]string)
+	err := stream.FilterKeys(func(k string) bool {
+		return strings.HasPrefix(k, "a")
+	}).MapValues(func(v int) string {
+		return strings.Repeat("*", v)
+	}).ToMap(&result, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result["avocado"] != "**" {
+		t.Errorf("unexpected map %v", result)
+	}
+}
+
+func TestToMap(t *testing.T) {
+	students := createStudents()
+	stream, _ := New(students)
+
+	var ages map[string]int
+	err := stream.Map(func(s student) Pair {
+		return Pair{Key: s.name, Value: s.age}
+	}).ToMap(&ages, nil)
+	fmt.Println(t.Name()+":", err)
+
+	stream.Reset()
+	err = stream.Map(func(s student) Pair {
+		return Pair{Key: s.name, Value: s.age}
+	}).ToMap(&ages, func(old, new int) int {
+		if new > old {
+			return new
+		}
+		return old
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range students {
+		if ages[s.name] < s.age {
+			t.Errorf("age of %s is %d, expected at least %d", s.name, ages[s.name], s.age)
+		}
+	}
+	fmt.Printf("\t%v\n", ages)
+}
+
+func TestToMapConversion(t *testing.T) {
+	stream, _ := New([]Pair{{Key: int32(1), Value: 2}, {Key: int64(2), Value: uint8(3)}})
+	var m map[int]float64
+	if err := stream.ToMap(&m, nil); err != nil || fmt.Sprint(m) != "map[1:2 2:3]" {
+		t.Errorf("unexpected map %v, %v", m, err)
+	}
+
+	cases := []interface{}{
+		[]Pair{{Key: 65, Value: 1}},
+		[]Pair{{Key: []int{1}, Value: 1}},
+		[]Pair{{Key: "a", Value: "1"}},
+	}
+	for _, pairs := range cases {
+		stream, _ := New(pairs)
+		var m map[string]int
+		err := stream.ToMap(&m, nil)
+		if err == nil || !strings.Contains(err.Error(), "cannot convert") {
+			t.Errorf("%v: expected a conversion error, got %v, %v", pairs, m, err)
+		}
+	}
+}
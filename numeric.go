@@ -0,0 +1,177 @@
+package stream
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Number is the constraint of the numeric types, including the types defined on them.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Statistics summarizes numbers, Variance and StdDev are the population variance and standard deviation.
+// The zero value is ready to use.
+type Statistics struct {
+	Count    int
+	Min      float64
+	Max      float64
+	Sum      float64
+	Mean     float64
+	Variance float64
+	StdDev   float64
+
+	m2 float64
+}
+
+// Add adds a number, the mean and variance are updated with Welford's algorithm.
+func (st *Statistics) Add(x float64) {
+	if st.Count == 0 || x < st.Min {
+		st.Min = x
+	}
+	if st.Count == 0 || x > st.Max {
+		st.Max = x
+	}
+	st.Count++
+	st.Sum += x
+	delta := x - st.Mean
+	st.Mean += delta / float64(st.Count)
+	st.m2 += delta * (x - st.Mean)
+	st.update()
+}
+
+// Merge adds the numbers summarized by other, as if they were added one by one.
+func (st *Statistics) Merge(other Statistics) {
+	if other.Count == 0 {
+		return
+	}
+	if st.Count == 0 {
+		*st = other
+		return
+	}
+	n1, n2 := float64(st.Count), float64(other.Count)
+	delta := other.Mean - st.Mean
+	st.Min = math.Min(st.Min, other.Min)
+	st.Max = math.Max(st.Max, other.Max)
+	st.Count += other.Count
+	st.Sum += other.Sum
+	st.Mean += delta * n2 / (n1 + n2)
+	st.m2 += other.m2 + delta*delta*n1*n2/(n1+n2)
+	st.update()
+}
+
+func (st *Statistics) update() {
+	st.Variance = st.m2 / float64(st.Count)
+	st.StdDev = math.Sqrt(st.Variance)
+}
+
+// Sum operation. Return the sum of the elements, which must be numbers, as a value of the element type.
+// Integers are added without loss of precision, elements of different types are added as float64.
+func (s *Stream) Sum() interface{} {
+	return s.sum(s.collect(), s.elemType())
+}
+
+// SumBy operation. Return the sum of the numbers the elements are mapped to, as a value of type N.
+// sumFunc: func(o T) N
+func (s *Stream) SumBy(sumFunc interface{}) interface{} {
+	funcValue := reflect.ValueOf(sumFunc)
+	data := s.collect()
+	numbers := make([]interface{}, len(data))
+	for i, it := range data {
+		numbers[i] = call(funcValue, it)[0].Interface()
+	}
+	return s.sum(numbers, funcValue.Type().Out(0))
+}
+
+// Average operation. Return the arithmetic mean of the elements, which must be numbers, or 0 if there is none.
+func (s *Stream) Average() float64 {
+	return s.Statistics().Mean
+}
+
+// Statistics operation. Return the count, min, max, sum, mean, variance and standard deviation of the elements,
+// which must be numbers.
+func (s *Stream) Statistics() Statistics {
+	var st Statistics
+	for _, it := range s.collect() {
+		x, ok := toFloat(it)
+		if !ok {
+			s.fail(fmt.Errorf("stream: element of type %T is not a number", it))
+			return Statistics{}
+		}
+		st.Add(x)
+	}
+	return st
+}
+
+// SumOf returns the sum of the elements of the stream as a T.
+func SumOf[T Number](s *Stream) T {
+	var sum T
+	if v := s.Sum(); v != nil {
+		sum = reflect.ValueOf(v).Convert(reflect.TypeOf(sum)).Interface().(T)
+	}
+	return sum
+}
+
+func (s *Stream) sum(numbers []interface{}, typ reflect.Type) interface{} {
+	var i int64
+	var u uint64
+	var f float64
+	for _, it := range numbers {
+		v := reflect.ValueOf(it)
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i += v.Int()
+			f += float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			u += v.Uint()
+			f += float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			f += v.Float()
+		default:
+			s.fail(fmt.Errorf("stream: element of type %T is not a number", it))
+			return nil
+		}
+		if typ == nil || !isNumber(typ) {
+			typ = v.Type()
+		} else if typ != v.Type() {
+			typ = reflect.TypeOf(f)
+		}
+	}
+	if typ == nil || !isNumber(typ) {
+		return 0
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.ValueOf(i).Convert(typ).Interface()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.ValueOf(u).Convert(typ).Interface()
+	}
+	return reflect.ValueOf(f).Convert(typ).Interface()
+}
+
+func isNumber(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// toFloat converts a number of any kind to float64.
+func toFloat(it interface{}) (float64, bool) {
+	v := reflect.ValueOf(it)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,87 @@
+package stream
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+type celsius float64
+
+func TestSum(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	ints, _ := Ints(1, 2, 3)
+	if sum := ints.Sum(); sum != int64(6) {
+		t.Errorf("expected int64 6, got %T %v", sum, sum)
+	}
+	floats, _ := Floats(1.5, 2.5)
+	if sum := floats.Sum(); sum != 4.0 {
+		t.Errorf("expected 4.0, got %v", sum)
+	}
+	temps, _ := Of(celsius(20.5), celsius(21.5))
+	if sum := temps.Sum(); sum != celsius(42) {
+		t.Errorf("expected celsius 42, got %T %v", sum, sum)
+	}
+	mixed, _ := Of(1, uint8(2), 0.5)
+	if sum := mixed.Sum(); sum != 3.5 {
+		t.Errorf("expected 3.5, got %T %v", sum, sum)
+	}
+	empty, _ := Ints()
+	if sum := empty.Filter(func(i int64) bool { return i > 0 }).Sum(); sum != int64(0) {
+		t.Errorf("expected int64 0, got %T %v", sum, sum)
+	}
+	strs, _ := Strings("a")
+	if sum := strs.Sum(); sum != nil || strs.Err() == nil {
+		t.Errorf("expected error, got %v", sum)
+	}
+	fmt.Println("\t", strs.Err())
+}
+
+func TestSumBy(t *testing.T) {
+	students := createStudents()
+	stream, _ := New(students)
+
+	expected := 0
+	for _, s := range students {
+		expected += s.scores[0]
+	}
+	sum := stream.SumBy(func(s student) int {
+		return s.scores[0]
+	})
+	if sum != expected {
+		t.Errorf("expected %d, got %v", expected, sum)
+	}
+	stream.Reset()
+	if avg := stream.Map(func(s student) int { return s.scores[0] }).Average(); math.Abs(avg-float64(expected)/10) > 1e-9 {
+		t.Errorf("expected average %v, got %v", float64(expected)/10, avg)
+	}
+	fmt.Printf("%s: %v\n", t.Name(), sum)
+}
+
+func TestSumOf(t *testing.T) {
+	stream, _ := Of(1, 2, 3)
+	if sum := SumOf[float64](stream); sum != 6 {
+		t.Errorf("expected 6, got %v", sum)
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	stream, _ := Ints(2, 4, 4, 4, 5, 5, 7, 9)
+	st := stream.Statistics()
+	if st.Count != 8 || st.Min != 2 || st.Max != 9 || st.Sum != 40 || st.Mean != 5 || st.Variance != 4 || st.StdDev != 2 {
+		t.Errorf("unexpected statistics %+v", st)
+	}
+
+	var a, b Statistics
+	for _, x := range []float64{2, 4, 4, 4} {
+		a.Add(x)
+	}
+	for _, x := range []float64{5, 5, 7, 9} {
+		b.Add(x)
+	}
+	a.Merge(b)
+	if a.Count != 8 || a.Min != 2 || a.Max != 9 || math.Abs(a.Mean-5) > 1e-9 || math.Abs(a.Variance-4) > 1e-9 {
+		t.Errorf("unexpected merged statistics %+v", a)
+	}
+	fmt.Printf("%s: %+v\n", t.Name(), st)
+}
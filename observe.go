@@ -0,0 +1,295 @@
+package stream
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of an Event.
+type EventKind int
+
+const (
+	// OpStart is sent for each operation before the stream is consumed.
+	OpStart EventKind = iota
+	// OpEnd is sent for each operation once the stream is consumed, with its counts and duration.
+	// The operations that pull their input in the background, such as MapAsync, are waited for: if one is
+	// blocked in a source that doesn't yield, such as a channel, the OpEnd events are sent once it yields.
+	OpEnd
+	// OpError is sent when the error that stops the stream occurs in an operation.
+	OpError
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case OpStart:
+		return "start"
+	case OpEnd:
+		return "end"
+	case OpError:
+		return "error"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// Event is what an Observer receives about an operation of a stream.
+type Event struct {
+	Kind EventKind
+	// Op is the position of the operation in the plan, see Explain, -1 for the source of the elements.
+	Op int
+	// Name is the kind of the operation, such as "filter", or "source".
+	Name string
+	// In and Out are the numbers of elements that entered and left the operation, set in OpEnd.
+	In, Out int
+	// Duration is the time spent in the operation, excluding the operations before it, set in OpEnd.
+	// For the operations that pull their input in the background, such as MapAsync, it is the time spent
+	// waiting for their results.
+	Duration time.Duration
+	// Err is the error that stopped the stream, set in OpError and in OpEnd of the operation where it occurred.
+	Err error
+}
+
+// Observer receives the events of the streams it is attached to, see Stream.Observe.
+// It must be safe for concurrent use, as operations such as MapAsync run in several goroutines.
+type Observer interface {
+	Observe(e Event)
+}
+
+// ObserverFunc is a function used as an Observer.
+type ObserverFunc func(e Event)
+
+// Observe calls f(e).
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// Observe attaches an observer that receives the events of each run of the stream.
+// Without an observer the operations aren't instrumented at all.
+func (s *Stream) Observe(observer Observer) *Stream {
+	s.observer = observer
+	return s
+}
+
+// tracer instruments the stages of a run. Slot 0 is the source, slot i the i-th operation from first.
+type tracer struct {
+	s       *Stream
+	first   int
+	start   time.Time
+	feeders []*feeder // of the stages that pull their input in the background
+	once    sync.Once
+
+	mu      sync.Mutex // guards slots and errSeen, the stages before a feeder run in its goroutine
+	slots   []traceSlot
+	errSeen bool
+}
+
+type traceSlot struct {
+	op    int
+	name  string
+	out   int
+	calls time.Duration
+	build time.Duration
+	err   error
+}
+
+func newTracer(s *Stream, first int) *tracer {
+	t := &tracer{s: s, first: first, slots: make([]traceSlot, len(s.ops)-first+1)}
+	t.slots[0] = traceSlot{op: -1, name: "source"}
+	if first > 0 {
+		t.slots[0].name = "cache"
+	}
+	for i, op := range s.ops[first:] {
+		t.slots[i+1] = traceSlot{op: first + i, name: op.typ}
+	}
+	for _, slot := range t.slots {
+		s.observer.Observe(Event{Kind: OpStart, Op: slot.op, Name: slot.name})
+	}
+	return t
+}
+
+// build starts timing the construction of a stage, the stages that read all their input do it there.
+func (t *tracer) build() {
+	t.start = time.Now()
+}
+
+// traced counts and times the elements the stage of slot k emits.
+func (t *tracer) traced(k int, next iterator) iterator {
+	slot := &t.slots[k]
+	if k > 0 {
+		slot.build = time.Since(t.start)
+	}
+	return func() (interface{}, bool) {
+		start := time.Now()
+		it, ok := next()
+		elapsed := time.Since(start)
+		var err error
+		if !ok {
+			err = t.s.Err()
+		}
+		t.mu.Lock()
+		slot.calls += elapsed
+		if ok {
+			slot.out++
+		} else if err != nil && !t.errSeen {
+			t.errSeen = true
+			slot.err = err
+		} else {
+			err = nil
+		}
+		t.mu.Unlock()
+		if err != nil {
+			t.s.observer.Observe(Event{Kind: OpError, Op: slot.op, Name: slot.name, Err: err})
+		}
+		return it, ok
+	}
+}
+
+// finish returns a done function that releases the stages, then sends the OpEnd events once the feeders have
+// exited. A feeder left blocked in the source sends them from its own goroutine when it exits.
+func (t *tracer) finish(done func()) func() {
+	return func() {
+		done()
+		t.once.Do(func() {
+			for _, f := range t.feeders {
+				select {
+				case <-f.exited:
+				default:
+					go func() {
+						for _, f := range t.feeders {
+							<-f.exited
+						}
+						t.end()
+					}()
+					return
+				}
+			}
+			t.end()
+		})
+	}
+}
+
+// end sends the OpEnd events.
+func (t *tracer) end() {
+	t.mu.Lock()
+	events := make([]Event, len(t.slots))
+	for k, slot := range t.slots {
+		e := Event{Kind: OpEnd, Op: slot.op, Name: slot.name, In: slot.out, Out: slot.out,
+			Duration: slot.calls, Err: slot.err}
+		if k > 0 {
+			e.In = t.slots[k-1].out
+			e.Duration = slot.build + slot.calls - t.slots[k-1].calls
+		}
+		if e.Duration < 0 {
+			e.Duration = 0
+		}
+		events[k] = e
+	}
+	t.mu.Unlock()
+	for _, e := range events {
+		t.s.observer.Observe(e)
+	}
+}
+
+// OpMetrics are the totals of an operation over the runs a Metrics observed.
+type OpMetrics struct {
+	Op       int
+	Name     string
+	Runs     int
+	In       int
+	Out      int
+	Errors   int
+	Duration time.Duration
+}
+
+// Metrics is an Observer that totals the counts and durations of each operation.
+// The zero value is ready to use.
+type Metrics struct {
+	mu  sync.Mutex
+	ops map[int]*OpMetrics
+}
+
+// NewMetrics create an empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// Observe adds the counts and duration of an OpEnd event.
+func (m *Metrics) Observe(e Event) {
+	if e.Kind != OpEnd {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ops == nil {
+		m.ops = make(map[int]*OpMetrics)
+	}
+	om, ok := m.ops[e.Op]
+	if !ok {
+		om = &OpMetrics{Op: e.Op, Name: e.Name}
+		m.ops[e.Op] = om
+	}
+	om.Runs++
+	om.In += e.In
+	om.Out += e.Out
+	om.Duration += e.Duration
+	if e.Err != nil {
+		om.Errors++
+	}
+}
+
+// Snapshot returns the totals of each operation, in the order of the plan.
+func (m *Metrics) Snapshot() []OpMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]OpMetrics, 0, len(m.ops))
+	for _, om := range m.ops {
+		result = append(result, *om)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Op < result[j].Op })
+	return result
+}
+
+// Reset forgets the totals.
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ops = nil
+}
+
+// String renders the totals one operation per line.
+func (m *Metrics) String() string {
+	var b strings.Builder
+	for _, om := range m.Snapshot() {
+		fmt.Fprintf(&b, "%d. %s: runs=%d in=%d out=%d errors=%d duration=%v\n",
+			om.Op, om.Name, om.Runs, om.In, om.Out, om.Errors, om.Duration)
+	}
+	return b.String()
+}
+
+// Var returns the totals as an expvar.Var, to publish them with expvar.Publish.
+func (m *Metrics) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		return m.Snapshot()
+	})
+}
+
+// NewSlogObserver create an Observer that logs the end of each operation at level, with its counts and
+// duration, and the errors at slog.LevelError.
+func NewSlogObserver(logger *slog.Logger, level slog.Level) Observer {
+	return ObserverFunc(func(e Event) {
+		switch e.Kind {
+		case OpEnd:
+			logger.LogAttrs(context.Background(), level, "stream operation",
+				slog.Int("op", e.Op), slog.String("name", e.Name), slog.Int("in", e.In),
+				slog.Int("out", e.Out), slog.Duration("duration", e.Duration))
+		case OpError:
+			logger.LogAttrs(context.Background(), slog.LevelError, "stream operation failed",
+				slog.Int("op", e.Op), slog.String("name", e.Name), slog.Any("error", e.Err))
+		}
+	})
+}
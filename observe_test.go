@@ -0,0 +1,169 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	var events []Event
+	stream, _ := GenN(10, func(i int) int { return i })
+	var result []int
+	stream.Observe(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	})).Filter(func(i int) bool {
+		return i%2 == 0
+	}).Map(func(i int) int {
+		time.Sleep(time.Millisecond)
+		return i
+	}).Limit(3).ToSlice(&result)
+
+	var starts, ends []string
+	for _, e := range events {
+		switch e.Kind {
+		case OpStart:
+			starts = append(starts, e.Name)
+		case OpEnd:
+			ends = append(ends, fmt.Sprintf("%d %s %d>%d", e.Op, e.Name, e.In, e.Out))
+		}
+	}
+	if fmt.Sprint(starts) != "[source filter map limit]" {
+		t.Errorf("unexpected start events %v", starts)
+	}
+	// the limit stops pulling once it has 3 elements
+	expected := "[-1 source 5>5 0 filter 5>3 1 map 3>3 2 limit 3>3]"
+	if fmt.Sprint(ends) != expected {
+		t.Errorf("expected end events %v, got %v", expected, ends)
+	}
+	for _, e := range events {
+		if e.Kind == OpEnd && e.Name == "map" && e.Duration < 3*time.Millisecond {
+			t.Errorf("expected the map to take at least 3ms, got %v", e.Duration)
+		}
+		if e.Kind == OpEnd && e.Name == "limit" && e.Duration > time.Millisecond {
+			t.Errorf("expected the limit to exclude the time of the map, got %v", e.Duration)
+		}
+	}
+	fmt.Println(t.Name()+":", ends)
+}
+
+func TestObserveError(t *testing.T) {
+	errOdd := errors.New("odd")
+	var failed []string
+	stream, _ := GenN(4, func(i int) int { return i })
+	stream.Observe(ObserverFunc(func(e Event) {
+		if e.Kind == OpError {
+			failed = append(failed, fmt.Sprint(e.Op, " ", e.Name, " ", e.Err))
+		}
+	})).Filter(func(i int) bool {
+		return true
+	}).MapRetry(func(i int) (int, error) {
+		if i == 1 {
+			return 0, errOdd
+		}
+		return i, nil
+	}, RetryPolicy{}).Map(func(i int) int {
+		return i
+	}).Count()
+	if fmt.Sprint(failed) != "[1 mapRetry odd]" {
+		t.Errorf("expected an error in the retried map, got %v", failed)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	for i := 0; i < 2; i++ {
+		stream, _ := GenN(5, func(i int) int { return i })
+		stream.Observe(metrics).MapAsync(func(i int) int { return i }, 2).Count()
+	}
+	snapshot := metrics.Snapshot()
+	if len(snapshot) != 2 || snapshot[1].Name != "mapAsync" || snapshot[1].Runs != 2 ||
+		snapshot[1].In != 10 || snapshot[1].Out != 10 {
+		t.Errorf("unexpected metrics %+v", snapshot)
+	}
+
+	var totals []OpMetrics
+	if err := json.Unmarshal([]byte(metrics.Var().String()), &totals); err != nil || len(totals) != 2 {
+		t.Errorf("unexpected expvar %s, %v", metrics.Var(), err)
+	}
+	fmt.Print(t.Name()+":\n", metrics)
+}
+
+func TestMetricsConcurrent(t *testing.T) {
+	metrics := NewMetrics()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stream, _ := GenN(5, func(i int) int { return i })
+			stream.Observe(metrics).Count()
+		}()
+	}
+	wg.Wait()
+	if s := metrics.Snapshot(); len(s) != 1 || s[0].Runs != 4 || s[0].Out != 20 {
+		t.Errorf("unexpected metrics %+v", s)
+	}
+}
+
+func TestSlogObserver(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	stream, _ := GenN(3, func(i int) int { return i })
+	stream.Observe(NewSlogObserver(logger, slog.LevelDebug)).Filter(func(i int) bool {
+		return i > 0
+	}).Count()
+	if !strings.Contains(buf.String(), "name=filter in=3 out=2") {
+		t.Errorf("unexpected log\n%s", buf.String())
+	}
+}
+
+func TestObserveBackground(t *testing.T) {
+	run := func(name string, build func(s *Stream) *Stream) {
+		ch := make(chan int)
+		go func() {
+			ch <- 1
+			time.Sleep(10 * time.Millisecond)
+			ch <- 2
+			close(ch)
+		}()
+		ends := make(chan Event, 10)
+		stream, _ := FromChan(ch)
+		var n int
+		within(t, 2*time.Second, func() {
+			n = build(stream.Observe(ObserverFunc(func(e Event) {
+				if e.Kind == OpEnd {
+					ends <- e
+				}
+			}))).Limit(1).Count()
+		})
+		if n != 1 {
+			t.Errorf("%s: expected 1 element, got %d", name, n)
+		}
+		// the feeder may be left blocked in the source, then the end events wait for it to read 2
+		var got []string
+		for i := 0; i < 3; i++ {
+			select {
+			case e := <-ends:
+				got = append(got, fmt.Sprintf("%s %d>%d", e.Name, e.In, e.Out))
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s: missing end events after %v", name, got)
+			}
+		}
+		if got[2] != "limit 1>1" {
+			t.Errorf("%s: unexpected end events %v", name, got)
+		}
+	}
+	run("mapAsync", func(s *Stream) *Stream {
+		return s.MapAsync(func(i int) int { return i }, 2)
+	})
+	run("batch", func(s *Stream) *Stream {
+		return s.Batch(2, 10*time.Millisecond)
+	})
+}
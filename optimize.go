@@ -0,0 +1,139 @@
+package stream
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// fused are the functions a fused operation calls in turn, for Explain.
+type fused []reflect.Value
+
+func (f fused) String() string {
+	names := make([]string, len(f))
+	for i, fun := range f {
+		names[i] = funcName(fun)
+	}
+	return strings.Join(names, " + ")
+}
+
+// Optimize rewrites the operations of the stream so that it does less work for the same elements:
+//   - a Sort followed by another Sort, possibly through Filters and Maps, is removed. As Sort isn't stable,
+//     the elements equal for the last Sort may end in another order;
+//   - a Limit is moved before the Maps that emit one element for each one in the same order and can't fail,
+//     so that a MapAsync doesn't map elements the Limit drops. A MapRetry, or a MapAsync whose function returns
+//     an error, stays before the Limit, as an OnErrorSkip added later may drop its failed elements;
+//   - adjacent Filters are fused into one, and adjacent Maps into one.
+//
+// The index operations are left as they are. The rewrites are reported by Explain.
+func (s *Stream) Optimize() *Stream {
+	for changed := true; changed; {
+		changed = s.removeSorts() || s.pushLimits() || s.fuse("filter", fuseFilters) || s.fuse("map", fuseMaps)
+	}
+	return s
+}
+
+func (s *Stream) rewrite(format string, args ...interface{}) {
+	s.rewrites = append(s.rewrites, fmt.Sprintf(format, args...))
+}
+
+// name formats an operation with its function for the rewrites.
+func (op op) name() string {
+	if f, ok := op.arg.(fused); ok {
+		return fmt.Sprintf("%s(%s)", op.typ, f)
+	}
+	if arg := op.describeArg(); arg != "" {
+		return fmt.Sprintf("%s(%s)", op.typ, arg)
+	}
+	if !op.fun.IsValid() {
+		return op.typ
+	}
+	return fmt.Sprintf("%s(%s)", op.typ, funcName(op.fun))
+}
+
+func (s *Stream) removeSorts() bool {
+	for i, op := range s.ops {
+		if op.typ != "sort" {
+			continue
+		}
+		for j := i + 1; j < len(s.ops); j++ {
+			next := s.ops[j]
+			if next.typ == "sort" {
+				s.rewrite("removed %s, sorted again by %s", op.name(), next.name())
+				s.ops = append(s.ops[:i], s.ops[i+1:]...)
+				return true
+			}
+			if next.idx || (next.typ != "filter" && next.typ != "map") {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// preservesOrder reports if the operation emits one element for each one, in the same order, whatever the error
+// handlers added after it.
+func (op op) preservesOrder() bool {
+	switch op.typ {
+	case "map":
+		return true
+	case "mapAsync":
+		return op.fun.Type().NumOut() == 1 && !op.arg.(asyncArg).unordered
+	}
+	return false
+}
+
+func (s *Stream) pushLimits() bool {
+	for i := 1; i < len(s.ops); i++ {
+		if s.ops[i].typ == "limit" && s.ops[i-1].preservesOrder() {
+			s.rewrite("moved %s before %s", s.ops[i].name(), s.ops[i-1].name())
+			s.ops[i-1], s.ops[i] = s.ops[i], s.ops[i-1]
+			return true
+		}
+	}
+	return false
+}
+
+// fuse replaces the first two adjacent operations of kind typ, which aren't index operations, with one.
+func (s *Stream) fuse(typ string, fuseFunc func(a, b reflect.Value) reflect.Value) bool {
+	for i := 1; i < len(s.ops); i++ {
+		a, b := s.ops[i-1], s.ops[i]
+		if a.typ != typ || b.typ != typ || a.idx || b.idx {
+			continue
+		}
+		s.rewrite("fused %s and %s", a.name(), b.name())
+		funcs := append(a.funcs(), b.funcs()...)
+		s.ops[i-1] = op{typ: typ, fun: fuseFunc(a.fun, b.fun), arg: fused(funcs), onErr: a.onErr}
+		s.ops = append(s.ops[:i], s.ops[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// funcs returns the functions the operation calls.
+func (op op) funcs() fused {
+	if f, ok := op.arg.(fused); ok {
+		return f
+	}
+	return fused{op.fun}
+}
+
+// fuseFilters returns a filter function keeping the elements both a and b keep.
+func fuseFilters(a, b reflect.Value) reflect.Value {
+	typ := reflect.FuncOf([]reflect.Type{a.Type().In(0)}, []reflect.Type{a.Type().Out(0)}, false)
+	return reflect.MakeFunc(typ, func(args []reflect.Value) []reflect.Value {
+		out := a.Call(args)
+		if !out[0].Bool() {
+			return out[:1]
+		}
+		return []reflect.Value{call(b, args[0].Interface())[0].Convert(typ.Out(0))}
+	})
+}
+
+// fuseMaps returns a map function applying a then b.
+func fuseMaps(a, b reflect.Value) reflect.Value {
+	typ := reflect.FuncOf([]reflect.Type{a.Type().In(0)}, []reflect.Type{b.Type().Out(0)}, false)
+	return reflect.MakeFunc(typ, func(args []reflect.Value) []reflect.Value {
+		return call(b, a.Call(args)[0].Interface())[:1]
+	})
+}
@@ -0,0 +1,138 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// optimized runs a pipeline as built and optimized, and checks they yield the same elements.
+func optimized(t *testing.T, build func(s *Stream) *Stream) (*Stream, []interface{}) {
+	t.Helper()
+	plain, _ := GenN(100, func(i int) int { return (i * 37) % 101 })
+	expected := build(plain).collect()
+	stream, _ := GenN(100, func(i int) int { return (i * 37) % 101 })
+	stream = build(stream).Optimize()
+	result := stream.collect()
+	if fmt.Sprint(result) != fmt.Sprint(expected) {
+		t.Errorf("optimized result %v differs from %v", result, expected)
+	}
+	return stream, result
+}
+
+func TestOptimizeFuse(t *testing.T) {
+	stream, result := optimized(t, func(s *Stream) *Stream {
+		return s.Filter(func(i int) bool {
+			return i%2 == 0
+		}).Filter(func(i int) bool {
+			return i%3 == 0
+		}).Filter(func(i int) bool {
+			return i > 10
+		}).Map(func(i int) string {
+			return fmt.Sprint(i)
+		}).Map(func(s string) int {
+			return len(s)
+		})
+	})
+	plan := stream.Explain()
+	if len(plan.Steps) != 2 || plan.Steps[0].Kind != "filter" || plan.Steps[1].Kind != "map" {
+		t.Errorf("expected a filter and a map, got\n%s", plan)
+	}
+	if len(plan.Rewrites) != 3 || !strings.Contains(plan.Steps[0].Func, " + ") {
+		t.Errorf("unexpected rewrites\n%s", plan)
+	}
+	if plan.Steps[1].Out.Kind().String() != "int" {
+		t.Errorf("expected the fused map to emit ints, got %v", plan.Steps[1].Out)
+	}
+	fmt.Print(t.Name()+": ", result, "\n", plan)
+}
+
+func TestOptimizeLimit(t *testing.T) {
+	var calls int32
+	stream, _ := optimized(t, func(s *Stream) *Stream {
+		atomic.StoreInt32(&calls, 0)
+		return s.MapAsync(func(i int) int {
+			atomic.AddInt32(&calls, 1)
+			return i * 2
+		}, 8).Map(func(i int) int {
+			return i + 1
+		}).Limit(3)
+	})
+	plan := stream.Explain()
+	if plan.Steps[0].Kind != "limit" || len(plan.Steps) != 3 {
+		t.Errorf("expected the limit first, got\n%s", plan)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls of the async map, got %d", calls)
+	}
+
+	// an index map and a filter keep the limit where it is
+	stream, _ = optimized(t, func(s *Stream) *Stream {
+		return s.Filter(func(i int) bool {
+			return i%2 == 0
+		}).MapIndex(func(o, i int) int {
+			return o * i
+		}).Limit(5)
+	})
+	if steps := stream.Explain().Steps; steps[1].Kind != "limit" {
+		t.Errorf("expected the limit after the filter, got %+v", steps)
+	}
+}
+
+func TestOptimizeLimitOnError(t *testing.T) {
+	mapFunc := func(i int) (int, error) {
+		if i == 2 {
+			return 0, fmt.Errorf("fail %d", i)
+		}
+		return i, nil
+	}
+	var plain, result []int
+	stream, _ := Of(1, 2, 3, 4)
+	stream.MapRetry(mapFunc, RetryPolicy{}).Limit(2).OnErrorSkip().ToSlice(&plain)
+	stream, _ = Of(1, 2, 3, 4)
+	stream.MapRetry(mapFunc, RetryPolicy{}).Limit(2).Optimize().OnErrorSkip().ToSlice(&result)
+	if fmt.Sprint(plain) != "[1 3]" || fmt.Sprint(result) != fmt.Sprint(plain) {
+		t.Errorf("expected [1 3] with and without Optimize, got %v and %v", plain, result)
+	}
+
+	result = nil
+	stream, _ = Of(1, 2, 3, 4)
+	stream.MapAsync(mapFunc, 2).Limit(2).Optimize().OnErrorSkip().ToSlice(&result)
+	if fmt.Sprint(result) != "[1 3]" {
+		t.Errorf("expected [1 3] with an async map, got %v", result)
+	}
+	if rewrites := stream.Explain().Rewrites; len(rewrites) != 0 {
+		t.Errorf("expected the limit to stay after the maps that can fail, got %v", rewrites)
+	}
+}
+
+func TestOptimizeSort(t *testing.T) {
+	stream, _ := optimized(t, func(s *Stream) *Stream {
+		return s.Sort(func(a, b int) bool {
+			return a > b
+		}).Filter(func(i int) bool {
+			return i%2 == 0
+		}).Sort(func(a, b int) bool {
+			return a < b
+		})
+	})
+	plan := stream.Explain()
+	if len(plan.Steps) != 2 || plan.Steps[0].Kind != "filter" {
+		t.Errorf("expected the first sort removed, got\n%s", plan)
+	}
+
+	// a distinct depends on the order, so the first sort is kept
+	stream, _ = optimized(t, func(s *Stream) *Stream {
+		return s.Sort(func(a, b int) bool {
+			return a > b
+		}).Distinct(func(a, b int) bool {
+			return a/10 == b/10
+		}).Sort(func(a, b int) bool {
+			return a < b
+		})
+	})
+	if plan := stream.Explain(); len(plan.Steps) != 3 || len(plan.Rewrites) != 0 {
+		t.Errorf("expected no rewrite, got\n%s", plan)
+	}
+}
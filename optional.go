@@ -0,0 +1,92 @@
+package stream
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Optional is the result of an operation that may have none, such as First or ReduceNoInit.
+// Unlike a nil result, it tells an absent value from a nil one.
+type Optional struct {
+	value   interface{}
+	present bool
+}
+
+// OptionalOf create an Optional of a value, which may be nil.
+func OptionalOf(value interface{}) Optional {
+	return Optional{value: value, present: true}
+}
+
+// EmptyOptional create an Optional without value.
+func EmptyOptional() Optional {
+	return Optional{}
+}
+
+// IsPresent reports if there is a value.
+func (o Optional) IsPresent() bool {
+	return o.present
+}
+
+// Get returns the value, nil if there is none.
+func (o Optional) Get() interface{} {
+	return o.value
+}
+
+// OrElse returns the value, or other if there is none.
+func (o Optional) OrElse(other interface{}) interface{} {
+	if o.present {
+		return o.value
+	}
+	return other
+}
+
+// OrElseGet returns the value, or the result of otherFunc if there is none.
+// otherFunc: func() T
+func (o Optional) OrElseGet(otherFunc interface{}) interface{} {
+	if o.present {
+		return o.value
+	}
+	return call(reflect.ValueOf(otherFunc))[0].Interface()
+}
+
+// Map returns an Optional of the value mapped by mapFunc, or an empty one if there is none.
+// mapFunc: func(o T1) T2
+func (o Optional) Map(mapFunc interface{}) Optional {
+	if !o.present {
+		return o
+	}
+	return OptionalOf(call(reflect.ValueOf(mapFunc), o.value)[0].Interface())
+}
+
+// Filter returns the Optional if its value matches filterFunc, or an empty one.
+// filterFunc: func(o T) bool
+func (o Optional) Filter(filterFunc interface{}) Optional {
+	if !o.present || !call(reflect.ValueOf(filterFunc), o.value)[0].Bool() {
+		return Optional{}
+	}
+	return o
+}
+
+// Into sets the variable target points to to the value and returns true. It returns false if there is no value,
+// if target isn't a non-nil pointer, or if the value doesn't convert to the type of the variable, as a float64
+// to a string. A nil value sets the variable to its zero value.
+func (o Optional) Into(target interface{}) bool {
+	targetValue := reflect.ValueOf(target)
+	if !o.present || targetValue.Kind() != reflect.Ptr || targetValue.IsNil() {
+		return false
+	}
+	value, err := convertTo(o.value, targetValue.Elem().Type())
+	if err != nil {
+		return false
+	}
+	targetValue.Elem().Set(value)
+	return true
+}
+
+// String formats the value as Optional[value], or Optional.empty if there is none.
+func (o Optional) String() string {
+	if !o.present {
+		return "Optional.empty"
+	}
+	return fmt.Sprintf("Optional[%v]", o.value)
+}
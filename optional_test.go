@@ -0,0 +1,66 @@
+package stream
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestOptional(t *testing.T) {
+	present, empty := OptionalOf(21), EmptyOptional()
+	if !present.IsPresent() || present.Get() != 21 || empty.IsPresent() {
+		t.Fatalf("unexpected optionals %v, %v", present, empty)
+	}
+	if present.OrElse(0) != 21 || empty.OrElse(0) != 0 {
+		t.Errorf("unexpected OrElse")
+	}
+	if empty.OrElseGet(func() int { return 7 }) != 7 {
+		t.Errorf("unexpected OrElseGet")
+	}
+	doubled := present.Map(func(i int) string { return fmt.Sprint(i * 2) })
+	if doubled.Get() != "42" || empty.Map(func(i int) int { return i }).IsPresent() {
+		t.Errorf("unexpected Map %v", doubled)
+	}
+	if present.Filter(func(i int) bool { return i > 30 }).IsPresent() ||
+		!present.Filter(func(i int) bool { return i > 20 }).IsPresent() {
+		t.Errorf("unexpected Filter")
+	}
+
+	var i64 int64 = -1
+	if !present.Into(&i64) || i64 != 21 || empty.Into(&i64) || i64 != 21 {
+		t.Errorf("unexpected Into %v", i64)
+	}
+	var str string
+	if OptionalOf(1.5).Into(&str) || OptionalOf(65).Into(&str) || present.Into(i64) || present.Into(nil) || str != "" {
+		t.Errorf("expected Into to refuse the value or the target, got %q", str)
+	}
+
+	// a present nil is not an absent value
+	var p *student
+	nilValue := OptionalOf(p)
+	if !nilValue.IsPresent() || !nilValue.Into(&p) || p != nil {
+		t.Errorf("expected a present nil pointer, got %v", nilValue)
+	}
+	fmt.Println(t.Name()+":", present, empty, doubled)
+}
+
+func TestFindFirst(t *testing.T) {
+	pulled := 0
+	stream, _ := GenN(10, func(i int) int { return i })
+	first := stream.Peek(func(i int) {
+		pulled++
+	}).Filter(func(i int) bool {
+		return i > 3
+	}).FindFirst()
+	if first.Get() != 4 || pulled != 5 {
+		t.Errorf("expected 4 after pulling 5 elements, got %v after %d", first, pulled)
+	}
+
+	stream, _ = New([]*student{nil})
+	if found := stream.FindAny(); !found.IsPresent() || found.Get().(*student) != nil {
+		t.Errorf("expected the nil element, got %v", found)
+	}
+	stream, _ = Ints()
+	if found := stream.FindAny(); found.IsPresent() {
+		t.Errorf("expected nothing, got %v", found)
+	}
+}
@@ -0,0 +1,110 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Partition operation. Append the elements matching matchFunc to the slice matched points to,
+// and the others to the slice unmatched points to, in one pass.
+// matchFunc: func(o T) bool
+func (s *Stream) Partition(matchFunc interface{}, matched, unmatched interface{}) error {
+	funcValue := reflect.ValueOf(matchFunc)
+	return s.split(func(i int, it interface{}) int {
+		if call(funcValue, it)[0].Bool() {
+			return 0
+		}
+		return 1
+	}, matched, unmatched)
+}
+
+// SplitAt operation. Append the first n elements to the slice head points to, and the others to the slice tail points to.
+func (s *Stream) SplitAt(n int, head, tail interface{}) error {
+	return s.split(func(i int, it interface{}) int {
+		if i < n {
+			return 0
+		}
+		return 1
+	}, head, tail)
+}
+
+// Span operation. Append the longest prefix of elements matching matchFunc to the slice head points to,
+// and the others, starting with the first element that doesn't match, to the slice tail points to.
+// matchFunc: func(o T) bool
+func (s *Stream) Span(matchFunc interface{}, head, tail interface{}) error {
+	funcValue := reflect.ValueOf(matchFunc)
+	spanning := true
+	return s.split(func(i int, it interface{}) int {
+		spanning = spanning && call(funcValue, it)[0].Bool()
+		if spanning {
+			return 0
+		}
+		return 1
+	}, head, tail)
+}
+
+// Route operation. Append each element to the slice its key points to in targets, a map[K]*[]T.
+// An element whose key isn't in targets is an error.
+// keyFunc: func(o T) K
+func (s *Stream) Route(keyFunc interface{}, targets interface{}) error {
+	funcValue := reflect.ValueOf(keyFunc)
+	targetsValue := reflect.ValueOf(targets)
+	if targetsValue.Kind() != reflect.Map {
+		return errors.New("targets must be a map")
+	}
+	keyType := targetsValue.Type().Key()
+	index := make(map[interface{}]int)
+	slices := make([]interface{}, 0)
+	for _, k := range targetsValue.MapKeys() {
+		index[k.Interface()] = len(slices)
+		slices = append(slices, targetsValue.MapIndex(k).Interface())
+	}
+	var routeErr error
+	err := s.split(func(i int, it interface{}) int {
+		out := call(funcValue, it)[0].Interface()
+		key, err := convertTo(out, keyType)
+		if err != nil {
+			routeErr = fmt.Errorf("key %v: %w", out, err)
+			return -1
+		}
+		j, ok := index[key.Interface()]
+		if !ok {
+			routeErr = fmt.Errorf("no target for key %v", key.Interface())
+			return -1
+		}
+		return j
+	}, slices...)
+	if routeErr != nil {
+		return routeErr
+	}
+	return err
+}
+
+// split appends each element to the target slice chosen by pick, a negative index stops.
+func (s *Stream) split(pick func(i int, it interface{}) int, targets ...interface{}) error {
+	slices := make([]reflect.Value, len(targets))
+	for i, target := range targets {
+		targetValue := reflect.ValueOf(target)
+		if targetValue.Kind() != reflect.Ptr || targetValue.Elem().Kind() != reflect.Slice {
+			return errors.New("target slice must be a pointer to a slice")
+		}
+		slices[i] = targetValue.Elem()
+	}
+	next, done := s.iterator()
+	defer done()
+	i := 0
+	for it, ok := next(); ok; it, ok = next() {
+		j := pick(i, it)
+		if j < 0 {
+			return nil
+		}
+		v := reflect.ValueOf(it)
+		if it == nil {
+			v = reflect.Zero(slices[j].Type().Elem())
+		}
+		slices[j].Set(reflect.Append(slices[j], v))
+		i++
+	}
+	return s.Err()
+}
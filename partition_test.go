@@ -0,0 +1,107 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPartition(t *testing.T) {
+	fmt.Println(t.Name() + ": by age > 20")
+	students := createStudents()
+	stream, _ := New(students)
+
+	var older, younger []student
+	if err := stream.Partition(func(s student) bool {
+		return s.age > 20
+	}, &older, &younger); err != nil {
+		t.Fatal(err)
+	}
+	if len(older)+len(younger) != len(students) {
+		t.Errorf("lost students: %d + %d", len(older), len(younger))
+	}
+	for _, s := range older {
+		if s.age <= 20 {
+			t.Errorf("unexpected %s", s.String())
+		}
+	}
+	fmt.Printf("\t%d %d\n", len(older), len(younger))
+
+	stream.Reset()
+	var wrong []student
+	fmt.Println("\t", stream.Partition(func(s student) bool { return true }, wrong, &younger))
+}
+
+func TestSplitAtSpan(t *testing.T) {
+	stream, _ := Ints(1, 2, 3, 10, 4, 5)
+	var head, tail []int64
+	stream.SplitAt(2, &head, &tail)
+	if fmt.Sprint(head, tail) != "[1 2] [3 10 4 5]" {
+		t.Errorf("unexpected split %v %v", head, tail)
+	}
+
+	head, tail = nil, nil
+	stream.Span(func(i int64) bool {
+		return i < 5
+	}, &head, &tail)
+	if fmt.Sprint(head, tail) != "[1 2 3] [10 4 5]" {
+		t.Errorf("unexpected span %v %v", head, tail)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	stream, _ := Strings("apple", "banana", "avocado", "cherry", "blueberry")
+	var a, b, other []string
+	err := stream.Route(func(s string) byte {
+		switch s[0] {
+		case 'a', 'b':
+			return s[0]
+		}
+		return '?'
+	}, map[byte]*[]string{'a': &a, 'b': &b, '?': &other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(a, b, other) != "[apple avocado] [banana blueberry] [cherry]" {
+		t.Errorf("unexpected routes %v %v %v", a, b, other)
+	}
+
+	stream.Reset()
+	err = stream.Route(func(s string) byte {
+		return s[0]
+	}, map[byte]*[]string{'a': &a})
+	fmt.Println(t.Name()+":", err)
+}
+
+func TestRouteKeys(t *testing.T) {
+	stream, _ := Ints(1, 2, 3)
+	var odd, even []int64
+	err := stream.Route(func(i int64) int64 { return i % 2 }, map[int]*[]int64{0: &even, 1: &odd})
+	if err != nil || fmt.Sprint(odd, even) != "[1 3] [2]" {
+		t.Errorf("unexpected routes %v %v, %v", odd, even, err)
+	}
+
+	// An int key doesn't become the string of a rune, nor does a key of another type panic.
+	for _, keyFunc := range []interface{}{func(i int64) int { return 65 }, func(i int64) []int { return nil }} {
+		stream.Reset()
+		var a []int64
+		err = stream.Route(keyFunc, map[string]*[]int64{"A": &a})
+		if err == nil || !strings.Contains(err.Error(), "cannot convert") {
+			t.Errorf("expected a conversion error, got %v, %v", a, err)
+		}
+	}
+}
+
+func TestSplitNil(t *testing.T) {
+	stream, _ := New([]interface{}{"a", nil, "b"})
+	var head, tail []interface{}
+	if err := stream.SplitAt(2, &head, &tail); err != nil || fmt.Sprint(head, tail) != "[a <nil>] [b]" {
+		t.Errorf("unexpected split %v %v, %v", head, tail, err)
+	}
+	stream, _ = New([]*student{nil, {name: "Tom"}})
+	var matched, unmatched []*student
+	err := stream.Partition(func(s *student) bool { return s == nil }, &matched, &unmatched)
+	if err != nil || len(matched) != 1 || matched[0] != nil || len(unmatched) != 1 {
+		t.Errorf("unexpected partition %v %v, %v", matched, unmatched, err)
+	}
+}
@@ -0,0 +1,419 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Registry holds named functions, so that a Pipeline can refer to them by name. It is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	funcs map[string]reflect.Value
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{funcs: make(map[string]reflect.Value)}
+}
+
+// Register adds a function under a name, fails if fn is not a function or the name is empty or taken.
+func (r *Registry) Register(name string, fn interface{}) error {
+	if name == "" {
+		return fmt.Errorf("stream: empty function name")
+	}
+	funcValue := reflect.ValueOf(fn)
+	if funcValue.Kind() != reflect.Func || funcValue.IsNil() {
+		return fmt.Errorf("stream: %s is a %T, not a function", name, fn)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.funcs[name]; ok {
+		return fmt.Errorf("stream: function %s is already registered", name)
+	}
+	r.funcs[name] = funcValue
+	return nil
+}
+
+// Lookup returns the function registered under a name.
+func (r *Registry) Lookup(name string) (interface{}, bool) {
+	fn, ok := r.lookup(name)
+	if !ok {
+		return nil, false
+	}
+	return fn.Interface(), true
+}
+
+func (r *Registry) lookup(name string) (reflect.Value, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fn, ok := r.funcs[name]
+	return fn, ok
+}
+
+// Names returns the names of the registered functions, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.funcs))
+	for name := range r.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pipeline is a definition of stream operations that can be stored or sent as JSON or YAML, and applied to streams
+// later. Functions are referred to by their name in a Registry, the other arguments are literals. A pipeline is
+// written with json.Marshal or yaml.Marshal, and read with ParsePipeline or ParsePipelineYAML.
+//
+//	{"steps": [{"op": "filter", "func": "isEven"}, {"op": "map", "func": "square"}, {"op": "limit", "args": [5]}]}
+//
+//	steps:
+//	  - {op: filter, func: isEven}
+//	  - {op: map, func: square}
+//	  - {op: limit, args: [5]}
+//
+// The ops are filter, filterIndex, map, mapIndex, flatMap, sort, distinct, peek and runningReduce, which take a
+// function, and limit, skip, pluck, sortByField, sortByFieldDesc, filterField and query, which take the arguments
+// of the Stream methods of the same name.
+type Pipeline struct {
+	Steps []Step `json:"steps" yaml:"steps"`
+}
+
+// Step is an operation of a Pipeline.
+type Step struct {
+	Op   string        `json:"op" yaml:"op"`
+	Func string        `json:"func,omitempty" yaml:"func,omitempty"`
+	Args []interface{} `json:"args,omitempty" yaml:"args,omitempty"`
+}
+
+// PipelineError is an error in a step of a Pipeline, Step is its index.
+type PipelineError struct {
+	Step int
+	Op   string
+	Err  error
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("pipeline: step %d (%s): %v", e.Step+1, e.Op, e.Err)
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// NewPipeline returns an empty pipeline.
+func NewPipeline() *Pipeline {
+	return &Pipeline{Steps: make([]Step, 0)}
+}
+
+// ParsePipeline decodes a pipeline from JSON, unknown ops and fields are errors.
+func ParsePipeline(data []byte) (*Pipeline, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	p := NewPipeline()
+	if err := decoder.Decode(p); err != nil {
+		return nil, fmt.Errorf("pipeline: %w", err)
+	}
+	if err := p.checkOps(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParsePipelineYAML decodes a pipeline from YAML, unknown ops and fields are errors.
+func ParsePipelineYAML(data []byte) (*Pipeline, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	p := NewPipeline()
+	if err := decoder.Decode(p); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("pipeline: %w", err)
+	}
+	if err := p.checkOps(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Pipeline) checkOps() error {
+	for i, step := range p.Steps {
+		if _, ok := pipelineOps[step.Op]; !ok {
+			return &PipelineError{Step: i, Op: step.Op, Err: fmt.Errorf("unknown op")}
+		}
+	}
+	return nil
+}
+
+// Filter adds a filter step, see Stream.Filter.
+func (p *Pipeline) Filter(funcName string) *Pipeline { return p.add("filter", funcName) }
+
+// FilterIndex adds a filter step with index, see Stream.FilterIndex.
+func (p *Pipeline) FilterIndex(funcName string) *Pipeline { return p.add("filterIndex", funcName) }
+
+// Map adds a map step, see Stream.Map.
+func (p *Pipeline) Map(funcName string) *Pipeline { return p.add("map", funcName) }
+
+// MapIndex adds a map step with index, see Stream.MapIndex.
+func (p *Pipeline) MapIndex(funcName string) *Pipeline { return p.add("mapIndex", funcName) }
+
+// FlatMap adds a flatMap step, see Stream.FlatMap.
+func (p *Pipeline) FlatMap(funcName string) *Pipeline { return p.add("flatMap", funcName) }
+
+// Sort adds a sort step, see Stream.Sort.
+func (p *Pipeline) Sort(funcName string) *Pipeline { return p.add("sort", funcName) }
+
+// Distinct adds a distinct step, see Stream.Distinct.
+func (p *Pipeline) Distinct(funcName string) *Pipeline { return p.add("distinct", funcName) }
+
+// Peek adds a peek step, see Stream.Peek.
+func (p *Pipeline) Peek(funcName string) *Pipeline { return p.add("peek", funcName) }
+
+// RunningReduce adds a runningReduce step without initial value, see Stream.RunningReduce.
+func (p *Pipeline) RunningReduce(funcName string) *Pipeline { return p.add("runningReduce", funcName) }
+
+// Limit adds a limit step, see Stream.Limit.
+func (p *Pipeline) Limit(num int) *Pipeline { return p.add("limit", "", num) }
+
+// Skip adds a skip step, see Stream.Skip.
+func (p *Pipeline) Skip(num int) *Pipeline { return p.add("skip", "", num) }
+
+// Pluck adds a pluck step, see Stream.Pluck.
+func (p *Pipeline) Pluck(path string) *Pipeline { return p.add("pluck", "", path) }
+
+// SortByField adds a sortByField step, see Stream.SortByField.
+func (p *Pipeline) SortByField(path string) *Pipeline { return p.add("sortByField", "", path) }
+
+// SortByFieldDesc adds a sortByFieldDesc step, see Stream.SortByFieldDesc.
+func (p *Pipeline) SortByFieldDesc(path string) *Pipeline { return p.add("sortByFieldDesc", "", path) }
+
+// FilterField adds a filterField step, see Stream.FilterField. The value must be a literal.
+func (p *Pipeline) FilterField(path string, operator string, value interface{}) *Pipeline {
+	return p.add("filterField", "", path, operator, value)
+}
+
+// Query adds the stages of a query, see Stream.Query.
+func (p *Pipeline) Query(text string) *Pipeline { return p.add("query", "", text) }
+
+func (p *Pipeline) add(typ string, funcName string, args ...interface{}) *Pipeline {
+	step := Step{Op: typ, Func: funcName}
+	if len(args) > 0 {
+		step.Args = args
+	}
+	p.Steps = append(p.Steps, step)
+	return p
+}
+
+// Validate checks the ops, the function names and shapes, and the arguments of the steps. The functions are
+// checked against the type of the elements by Apply, once the stream is known.
+func (p *Pipeline) Validate(r *Registry) error {
+	for i, step := range p.Steps {
+		if _, _, _, err := step.resolve(r); err != nil {
+			return &PipelineError{Step: i, Op: step.Op, Err: err}
+		}
+	}
+	return nil
+}
+
+// Apply adds the steps of the pipeline to a stream, looking up the functions in r. A step whose function doesn't
+// take the elements of the stream, or is unknown, fails the stream with a *PipelineError, as do invalid arguments
+// and steps the stream rejects, such as a query with a syntax error or a field path the elements don't have.
+func (p *Pipeline) Apply(s *Stream, r *Registry) *Stream {
+	s.mu.Lock()
+	bad := s.bad
+	s.mu.Unlock()
+	if bad != nil {
+		return s
+	}
+	for i, step := range p.Steps {
+		err := step.apply(s, r)
+		if err == nil {
+			// The step was added with a Stream method that failed, such as Query or FilterField.
+			err = s.takeOp()
+		}
+		if err != nil {
+			s.failOp(&PipelineError{Step: i, Op: step.Op, Err: err})
+			return s
+		}
+	}
+	return s
+}
+
+// pipelineOp describes an op of a pipeline: the function it takes if any, and its literal arguments.
+type pipelineOp struct {
+	elems int  // the number of elements the function takes, 0 if there is no function
+	index bool // the function takes the index after the element
+	out   func(reflect.Type) error
+	args  []reflect.Type // the types of the literal arguments, anyType takes any literal
+	apply func(s *Stream, fn interface{}, args []interface{})
+}
+
+var (
+	intType    = reflect.TypeOf(0)
+	stringType = reflect.TypeOf("")
+	boolType   = reflect.TypeOf(true)
+)
+
+func returns(types ...reflect.Type) func(reflect.Type) error {
+	return func(t reflect.Type) error {
+		if t.NumOut() != len(types) {
+			return fmt.Errorf("must return %d values", len(types))
+		}
+		for i, typ := range types {
+			if t.Out(i) != typ {
+				return fmt.Errorf("must return %s, not %s", typ, t.Out(i))
+			}
+		}
+		return nil
+	}
+}
+
+func returnsOne(t reflect.Type) error {
+	if t.NumOut() != 1 {
+		return fmt.Errorf("must return 1 value")
+	}
+	return nil
+}
+
+func returnsSlice(t reflect.Type) error {
+	if t.NumOut() != 1 || t.Out(0).Kind() != reflect.Slice {
+		return fmt.Errorf("must return a slice")
+	}
+	return nil
+}
+
+var pipelineOps = map[string]pipelineOp{
+	"filter":        {elems: 1, out: returns(boolType), apply: func(s *Stream, fn interface{}, _ []interface{}) { s.Filter(fn) }},
+	"filterIndex":   {elems: 1, index: true, out: returns(boolType), apply: func(s *Stream, fn interface{}, _ []interface{}) { s.FilterIndex(fn) }},
+	"map":           {elems: 1, out: returnsOne, apply: func(s *Stream, fn interface{}, _ []interface{}) { s.Map(fn) }},
+	"mapIndex":      {elems: 1, index: true, out: returnsOne, apply: func(s *Stream, fn interface{}, _ []interface{}) { s.MapIndex(fn) }},
+	"flatMap":       {elems: 1, out: returnsSlice, apply: func(s *Stream, fn interface{}, _ []interface{}) { s.FlatMap(fn) }},
+	"sort":          {elems: 2, out: returns(boolType), apply: func(s *Stream, fn interface{}, _ []interface{}) { s.Sort(fn) }},
+	"distinct":      {elems: 2, out: returns(boolType), apply: func(s *Stream, fn interface{}, _ []interface{}) { s.Distinct(fn) }},
+	"peek":          {elems: 1, out: returns(), apply: func(s *Stream, fn interface{}, _ []interface{}) { s.Peek(fn) }},
+	"runningReduce": {elems: 2, out: returnsOne, apply: func(s *Stream, fn interface{}, _ []interface{}) { s.RunningReduce(fn) }},
+	"limit": {args: []reflect.Type{intType}, apply: func(s *Stream, _ interface{}, args []interface{}) {
+		s.Limit(args[0].(int))
+	}},
+	"skip": {args: []reflect.Type{intType}, apply: func(s *Stream, _ interface{}, args []interface{}) {
+		s.Skip(args[0].(int))
+	}},
+	"pluck": {args: []reflect.Type{stringType}, apply: func(s *Stream, _ interface{}, args []interface{}) {
+		s.Pluck(args[0].(string))
+	}},
+	"sortByField": {args: []reflect.Type{stringType}, apply: func(s *Stream, _ interface{}, args []interface{}) {
+		s.SortByField(args[0].(string))
+	}},
+	"sortByFieldDesc": {args: []reflect.Type{stringType}, apply: func(s *Stream, _ interface{}, args []interface{}) {
+		s.SortByFieldDesc(args[0].(string))
+	}},
+	"filterField": {args: []reflect.Type{stringType, stringType, anyType}, apply: func(s *Stream, _ interface{}, args []interface{}) {
+		s.FilterField(args[0].(string), args[1].(string), args[2])
+	}},
+	"query": {args: []reflect.Type{stringType}, apply: func(s *Stream, _ interface{}, args []interface{}) {
+		s.Query(args[0].(string))
+	}},
+}
+
+// resolve returns the op of the step, its function if it takes one and its arguments converted to their types.
+func (step Step) resolve(r *Registry) (op pipelineOp, fn reflect.Value, args []interface{}, err error) {
+	op, ok := pipelineOps[step.Op]
+	if !ok {
+		return op, fn, nil, fmt.Errorf("unknown op")
+	}
+	if op.elems == 0 && step.Func != "" {
+		return op, fn, nil, fmt.Errorf("takes no function, got %s", step.Func)
+	}
+	if op.elems > 0 {
+		if step.Func == "" {
+			return op, fn, nil, fmt.Errorf("missing function")
+		}
+		if fn, ok = r.lookup(step.Func); !ok {
+			return op, fn, nil, fmt.Errorf("unknown function %s", step.Func)
+		}
+		if err := op.checkFunc(fn.Type(), nil); err != nil {
+			return op, fn, nil, fmt.Errorf("function %s %v", step.Func, err)
+		}
+	}
+	if len(step.Args) != len(op.args) {
+		return op, fn, nil, fmt.Errorf("takes %d arguments, got %d", len(op.args), len(step.Args))
+	}
+	args = make([]interface{}, len(op.args))
+	for i, typ := range op.args {
+		if args[i], err = literal(step.Args[i], typ); err != nil {
+			return op, fn, nil, fmt.Errorf("argument %d: %v", i+1, err)
+		}
+	}
+	return op, fn, args, nil
+}
+
+// checkFunc checks the type of a function of the op, elem is the type of the elements if known.
+func (op pipelineOp) checkFunc(t reflect.Type, elem reflect.Type) error {
+	in := op.elems
+	if op.index {
+		in++
+	}
+	if t.NumIn() != in || t.IsVariadic() {
+		return fmt.Errorf("must take %d arguments, not %s", in, t)
+	}
+	for i := 0; i < op.elems; i++ {
+		if elem != nil && elem.Kind() != reflect.Interface && !takes(t.In(i), elem) {
+			return fmt.Errorf("must take %s, not %s", elem, t.In(i))
+		}
+	}
+	if op.index && t.In(op.elems) != intType {
+		return fmt.Errorf("must take an int index, not %s", t.In(op.elems))
+	}
+	return op.out(t)
+}
+
+// takes reports whether a function parameter of type in takes elements of type elem: when elem is assignable to
+// it, or both are numbers or of the same kind, unlike a string parameter for int elements that convert to runes.
+func takes(in, elem reflect.Type) bool {
+	if elem.AssignableTo(in) || isNumber(elem) && isNumber(in) {
+		return true
+	}
+	return elem.Kind() == in.Kind() && elem.ConvertibleTo(in)
+}
+
+// literal converts a literal argument to a type. Integers decoded as float64 are accepted as int.
+func literal(v interface{}, t reflect.Type) (interface{}, error) {
+	switch t {
+	case anyType:
+		return v, nil
+	case intType:
+		if f, ok := toFloat(v); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return int(f), nil
+		}
+		return nil, fmt.Errorf("expected an integer, got %v", v)
+	case stringType:
+		if str, ok := v.(string); ok {
+			return str, nil
+		}
+		return nil, fmt.Errorf("expected a string, got %v", v)
+	}
+	return nil, fmt.Errorf("unsupported argument type %s", t)
+}
+
+func (step Step) apply(s *Stream, r *Registry) error {
+	op, fn, args, err := step.resolve(r)
+	if err != nil {
+		return err
+	}
+	var funcArg interface{}
+	if fn.IsValid() {
+		if err := op.checkFunc(fn.Type(), s.elemType()); err != nil {
+			return fmt.Errorf("function %s %v", step.Func, err)
+		}
+		funcArg = fn.Interface()
+	}
+	op.apply(s, funcArg, args)
+	return nil
+}
@@ -0,0 +1,217 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.yaml.in/yaml/v3"
+)
+
+func pipelineRegistry(t *testing.T) *Registry {
+	r := NewRegistry()
+	funcs := map[string]interface{}{
+		"isEven":  func(i int) bool { return i%2 == 0 },
+		"square":  func(i int) int { return i * i },
+		"itoa":    func(i int) string { return fmt.Sprint(i) },
+		"desc":    func(a, b int) bool { return a > b },
+		"sum":     func(a, b int) int { return a + b },
+		"isOdd":   func(i int, _ int) bool { return i%2 == 1 },
+		"twice":   func(i int) []int { return []int{i, i} },
+		"isEmpty": func(s string) bool { return s == "" },
+	}
+	for name, fn := range funcs {
+		if err := r.Register(name, fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func TestRegistry(t *testing.T) {
+	r := pipelineRegistry(t)
+	if fmt.Sprint(r.Names()) != "[desc isEmpty isEven isOdd itoa square sum twice]" {
+		t.Errorf("unexpected names %v", r.Names())
+	}
+	if fn, ok := r.Lookup("square"); !ok || fn.(func(int) int)(3) != 9 {
+		t.Errorf("expected square to be registered")
+	}
+	if _, ok := r.Lookup("cube"); ok {
+		t.Errorf("expected cube not to be registered")
+	}
+	for name, fn := range map[string]interface{}{"": func() {}, "square": func() {}, "five": 5} {
+		if err := r.Register(name, fn); err == nil {
+			t.Errorf("expected registering %q to fail", name)
+		}
+	}
+}
+
+func TestPipelineJSON(t *testing.T) {
+	r := pipelineRegistry(t)
+	p := NewPipeline().Filter("isEven").Map("square").Sort("desc").Skip(1).Limit(2)
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"steps":[{"op":"filter","func":"isEven"},{"op":"map","func":"square"},{"op":"sort","func":"desc"},` +
+		`{"op":"skip","args":[1]},{"op":"limit","args":[2]}]}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	loaded, err := ParsePipeline(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Validate(r); err != nil {
+		t.Fatal(err)
+	}
+	stream, _ := GenN(10, func(i int) int { return i + 1 })
+	var result []int
+	if err := loaded.Apply(stream, r).ToSlice(&result); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(result) != "[64 36]" {
+		t.Errorf("unexpected result %v", result)
+	}
+	fmt.Println(t.Name()+":", result)
+}
+
+func TestPipelineYAML(t *testing.T) {
+	r := pipelineRegistry(t)
+	p := NewPipeline().Filter("isEven").Map("square").Sort("desc").Skip(1).Limit(2).FilterField("Age", ">=", 2.5)
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ParsePipelineYAML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, p) {
+		t.Errorf("expected %v after a round trip, got %v", p.Steps, loaded.Steps)
+	}
+
+	doc := `
+steps:
+  - {op: filter, func: isEven}
+  - op: map
+    func: square
+  - {op: limit, args: [2]}
+`
+	if loaded, err = ParsePipelineYAML([]byte(doc)); err != nil {
+		t.Fatal(err)
+	}
+	stream, _ := GenN(10, func(i int) int { return i + 1 })
+	var result []int
+	if err := loaded.Apply(stream, r).ToSlice(&result); err != nil || fmt.Sprint(result) != "[4 16]" {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+	fmt.Println(t.Name()+":", result)
+
+	for doc, msg := range map[string]string{
+		"steps:\n  - {op: fold}":            "step 1 (fold): unknown op",
+		"steps:\n  - {op: map, fn: square}": "field fn not found",
+		"steps: {op: map}":                  "cannot unmarshal",
+	} {
+		if _, err := ParsePipelineYAML([]byte(doc)); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%q: expected an error with %q, got %v", doc, msg, err)
+		}
+	}
+}
+
+func TestPipelineOps(t *testing.T) {
+	r := pipelineRegistry(t)
+	cases := []struct {
+		pipeline *Pipeline
+		expected string
+	}{
+		{NewPipeline().FilterIndex("isOdd").FlatMap("twice").Map("itoa"), "[1 1 3 3 5 5]"},
+		{NewPipeline().RunningReduce("sum").Limit(4), "[1 3 6 10]"},
+		{&Pipeline{Steps: []Step{{Op: "runningReduce", Func: "sum"}, {Op: "skip", Args: []interface{}{4}}}}, "[15 21]"},
+		// Steps built by hand hold ints rather than the float64 of JSON.
+		{&Pipeline{Steps: []Step{{Op: "skip", Args: []interface{}{4}}, {Op: "map", Func: "square"}}}, "[25 36]"},
+	}
+	for _, c := range cases {
+		stream, _ := GenN(6, func(i int) int { return i + 1 })
+		var result []interface{}
+		c.pipeline.Apply(stream, r).ForEach(func(it interface{}) { result = append(result, it) })
+		if err := stream.Err(); err != nil || fmt.Sprint(result) != c.expected {
+			t.Errorf("%v: expected %s, got %v, %v", c.pipeline.Steps, c.expected, result, err)
+		}
+	}
+
+	stream, _ := New(createEmployees())
+	var names []string
+	p := NewPipeline().FilterField("Age", ">", 25).Query("sort by age").Pluck("Name")
+	data, _ := json.Marshal(p)
+	loaded, _ := ParsePipeline(data)
+	if err := loaded.Apply(stream, r).ToSlice(&names); err != nil || fmt.Sprint(names) != "[Lucy Kate King]" {
+		t.Errorf("unexpected names %v, %v", names, err)
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	r := pipelineRegistry(t)
+	cases := map[string]string{
+		`{"steps": [{"op": "fold", "func": "sum"}]}`:                                  "step 1 (fold): unknown op",
+		`{"steps": [{"op": "scan", "func": "sum"}]}`:                                  "step 1 (scan): unknown op",
+		`{"steps": [{"op": "limit", "args": [5]}, {"op": "map"}]}`:                    "step 2 (map): missing function",
+		`{"steps": [{"op": "map", "func": "cube"}]}`:                                  "step 1 (map): unknown function cube",
+		`{"steps": [{"op": "filter", "func": "square"}]}`:                             "function square must return bool, not int",
+		`{"steps": [{"op": "sort", "func": "isEven"}]}`:                               "function isEven must take 2 arguments",
+		`{"steps": [{"op": "limit", "func": "isEven", "args": [1]}]}`:                 "takes no function, got isEven",
+		`{"steps": [{"op": "limit", "args": ["5"]}]}`:                                 "argument 1: expected an integer, got 5",
+		`{"steps": [{"op": "skip", "args": [1.5]}]}`:                                  "argument 1: expected an integer, got 1.5",
+		`{"steps": [{"op": "pluck", "args": []}]}`:                                    "takes 1 arguments, got 0",
+		`{"steps": [{"op": "map", "func": "itoa"}, {"op": "map", "func": "square"}]}`: "step 2 (map): function square must take string, not int",
+		`{"steps": [{"op": "filter", "func": "isEmpty"}]}`:                            "function isEmpty must take int, not string",
+		`{"steps": [{"op": "map", "fn": "square"}]}`:                                  `unknown field "fn"`,
+	}
+	for data, msg := range cases {
+		var err error
+		p, err := ParsePipeline([]byte(data))
+		if err == nil {
+			stream, _ := New([]int{1, 2, 3})
+			err = p.Apply(stream, r).Err()
+		}
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: expected an error with %q, got %v", data, msg, err)
+		}
+	}
+
+	p := NewPipeline().Map("cube")
+	var pe *PipelineError
+	if err := p.Validate(r); !errors.As(err, &pe) || pe.Step != 0 || pe.Op != "map" {
+		t.Errorf("expected a PipelineError, got %v", err)
+	}
+
+	steps := []struct {
+		pipeline *Pipeline
+		step     int
+		op       string
+	}{
+		{NewPipeline().Limit(1).Query("where age >"), 1, "query"},
+		{NewPipeline().Query("where nme == 'Tom'"), 0, "query"},
+		{NewPipeline().Skip(1).FilterField("Age", "<>", 20), 1, "filterField"},
+		{NewPipeline().Map("itoa").Pluck("Name"), 1, "pluck"},
+	}
+	for _, c := range steps {
+		stream, _ := New(createEmployees())
+		if c.op == "pluck" {
+			stream, _ = New([]int{1, 2, 3})
+		}
+		err := c.pipeline.Apply(stream, r).Err()
+		if !errors.As(err, &pe) || pe.Step != c.step || pe.Op != c.op {
+			t.Errorf("%v: expected a PipelineError at step %d, got %v", c.pipeline.Steps, c.step+1, err)
+		}
+	}
+	var qe *QueryError
+	stream, _ := New(createEmployees())
+	if err := NewPipeline().Query("sort age").Apply(stream, r).Err(); !errors.As(err, &qe) {
+		t.Errorf("expected the QueryError to be wrapped, got %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package stream
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Histogram counts numbers in buckets. Counts[i] is the count of the numbers in [Bounds[i-1], Bounds[i]),
+// the first bucket has no lower bound and the last no upper bound, so there is one more count than bounds.
+type Histogram struct {
+	Bounds []float64
+	Counts []int
+}
+
+// Quantiles operation. Return the exact quantiles qs of the elements, which must be numbers.
+// Each q is in [0, 1], values between two elements are linearly interpolated. The quantiles of no element are NaN.
+func (s *Stream) Quantiles(qs ...float64) []float64 {
+	if !s.checkQuantiles(qs) {
+		return nil
+	}
+	numbers := s.floats()
+	sort.Float64s(numbers)
+	result := make([]float64, len(qs))
+	for i, q := range qs {
+		result[i] = quantile(numbers, q)
+	}
+	return result
+}
+
+// checkQuantiles fails the stream if a q isn't in [0, 1], before its elements are read.
+func (s *Stream) checkQuantiles(qs []float64) bool {
+	for _, q := range qs {
+		if !(q >= 0 && q <= 1) {
+			s.fail(fmt.Errorf("stream: quantile %v is not in [0, 1]", q))
+			return false
+		}
+	}
+	return true
+}
+
+// QuantilesApprox operation. Return the approximate quantiles qs of the elements, which must be numbers,
+// estimated with a t-digest of the given compression. The elements aren't kept in memory, so it suits
+// streams too large for Quantiles. A compression of 100 is usually accurate enough.
+func (s *Stream) QuantilesApprox(compression float64, qs ...float64) []float64 {
+	if !s.checkQuantiles(qs) {
+		return nil
+	}
+	digest := NewTDigest(compression)
+	next, done := s.iterator()
+	defer done()
+	for it, ok := next(); ok; it, ok = next() {
+		x, ok := toFloat(it)
+		if !ok {
+			s.fail(fmt.Errorf("stream: element of type %T is not a number", it))
+			return nil
+		}
+		digest.Add(x)
+	}
+	result := make([]float64, len(qs))
+	for i, q := range qs {
+		result[i] = digest.Quantile(q)
+	}
+	return result
+}
+
+// Median operation. Return the exact median of the elements, which must be numbers.
+func (s *Stream) Median() float64 {
+	q := s.Quantiles(0.5)
+	if q == nil {
+		return math.NaN()
+	}
+	return q[0]
+}
+
+// Histogram operation. Count the elements, which must be numbers, in the buckets delimited by bounds.
+// bounds must be in ascending order.
+func (s *Stream) Histogram(bounds []float64) Histogram {
+	if !sort.Float64sAreSorted(bounds) {
+		s.fail(fmt.Errorf("stream: histogram bounds %v are not in ascending order", bounds))
+		return Histogram{}
+	}
+	h := Histogram{Bounds: bounds, Counts: make([]int, len(bounds)+1)}
+	next, done := s.iterator()
+	defer done()
+	for it, ok := next(); ok; it, ok = next() {
+		x, ok := toFloat(it)
+		if !ok {
+			s.fail(fmt.Errorf("stream: element of type %T is not a number", it))
+			return Histogram{}
+		}
+		h.Counts[sort.Search(len(bounds), func(i int) bool { return x < bounds[i] })]++
+	}
+	return h
+}
+
+// HistogramAuto operation. Count the elements, which must be numbers, in n buckets of equal width
+// between the smallest and the largest element.
+func (s *Stream) HistogramAuto(n int) Histogram {
+	if n < 1 {
+		n = 1
+	}
+	numbers := s.floats()
+	bounds := make([]float64, n-1)
+	if len(numbers) > 0 {
+		min, max := numbers[0], numbers[0]
+		for _, x := range numbers {
+			min, max = math.Min(min, x), math.Max(max, x)
+		}
+		width := (max - min) / float64(n)
+		for i := range bounds {
+			bounds[i] = min + width*float64(i+1)
+		}
+	}
+	h := Histogram{Bounds: bounds, Counts: make([]int, n)}
+	for _, x := range numbers {
+		h.Counts[sort.Search(len(bounds), func(i int) bool { return x < bounds[i] })]++
+	}
+	return h
+}
+
+// floats collects the elements as float64, which must be numbers.
+func (s *Stream) floats() []float64 {
+	data := s.collect()
+	numbers := make([]float64, len(data))
+	for i, it := range data {
+		x, ok := toFloat(it)
+		if !ok {
+			s.fail(fmt.Errorf("stream: element of type %T is not a number", it))
+			return nil
+		}
+		numbers[i] = x
+	}
+	return numbers
+}
+
+// quantile returns the q quantile of sorted numbers.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (sorted[i+1]-sorted[i])*(pos-float64(i))
+}
+
+// TDigest estimates quantiles of a large number of values in a small, bounded memory.
+// It is the merging t-digest of Dunning and Ertl, digests of partitions of the values can be merged.
+type TDigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	count       float64
+	min, max    float64
+}
+
+type centroid struct {
+	mean, weight float64
+}
+
+// NewTDigest create a t-digest, a higher compression is more accurate and uses more memory.
+func NewTDigest(compression float64) *TDigest {
+	if compression < 10 {
+		compression = 10
+	}
+	return &TDigest{compression: compression, min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// Add adds a value.
+func (d *TDigest) Add(x float64) {
+	d.add(centroid{mean: x, weight: 1})
+}
+
+// Merge adds the values of other.
+func (d *TDigest) Merge(other *TDigest) {
+	for _, c := range other.centroids {
+		d.add(c)
+	}
+	for _, c := range other.buffer {
+		d.add(c)
+	}
+	d.min, d.max = math.Min(d.min, other.min), math.Max(d.max, other.max)
+}
+
+// Count returns the number of values added.
+func (d *TDigest) Count() int {
+	return int(d.count)
+}
+
+// Quantile returns the estimated q quantile, NaN if no value was added.
+func (d *TDigest) Quantile(q float64) float64 {
+	d.compress()
+	if d.count == 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return d.min
+	}
+	if q >= 1 {
+		return d.max
+	}
+	target := q * d.count
+	prevMean, prevCenter := d.min, 0.0
+	cum := 0.0
+	for _, c := range d.centroids {
+		center := cum + c.weight/2
+		if target < center {
+			return prevMean + (c.mean-prevMean)*(target-prevCenter)/(center-prevCenter)
+		}
+		prevMean, prevCenter = c.mean, center
+		cum += c.weight
+	}
+	return prevMean + (d.max-prevMean)*(target-prevCenter)/(d.count-prevCenter)
+}
+
+func (d *TDigest) add(c centroid) {
+	d.buffer = append(d.buffer, c)
+	d.count += c.weight
+	d.min, d.max = math.Min(d.min, c.mean), math.Max(d.max, c.mean)
+	if len(d.buffer) >= int(d.compression)*5 {
+		d.compress()
+	}
+}
+
+// compress merges the buffered values into the centroids, keeping each centroid within the size allowed
+// by the k1 scale function.
+func (d *TDigest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+	all := append(d.centroids, d.buffer...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+	merged := make([]centroid, 0, len(d.centroids)+1)
+	cur := all[0]
+	before := 0.0
+	limit := d.count * d.kInv(d.k(0)+1)
+	for _, c := range all[1:] {
+		if before+cur.weight+c.weight <= limit {
+			cur.mean += (c.mean - cur.mean) * c.weight / (cur.weight + c.weight)
+			cur.weight += c.weight
+			continue
+		}
+		before += cur.weight
+		merged = append(merged, cur)
+		limit = d.count * d.kInv(d.k(before/d.count)+1)
+		cur = c
+	}
+	d.centroids = append(merged, cur)
+	d.buffer = nil
+}
+
+func (d *TDigest) k(q float64) float64 {
+	return d.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+func (d *TDigest) kInv(k float64) float64 {
+	if k >= d.compression/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/d.compression) + 1) / 2
+}
@@ -0,0 +1,97 @@
+package stream
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestQuantiles(t *testing.T) {
+	stream, _ := Ints(5, 1, 4, 2, 3)
+	q := stream.Quantiles(0, 0.25, 0.5, 0.9, 1)
+	if fmt.Sprint(q) != "[1 2 3 4.6 5]" {
+		t.Errorf("unexpected quantiles %v", q)
+	}
+	stream.Reset()
+	if m := stream.Median(); m != 3 {
+		t.Errorf("expected median 3, got %v", m)
+	}
+	stream.Reset()
+	if q := stream.Quantiles(1.5); q != nil || stream.Err() == nil {
+		t.Errorf("expected error, got %v", q)
+	}
+	empty, _ := Floats()
+	if m := empty.Median(); !math.IsNaN(m) {
+		t.Errorf("expected NaN, got %v", m)
+	}
+	fmt.Println(t.Name()+":", q)
+}
+
+func TestQuantilesInvalidSingleUse(t *testing.T) {
+	for _, quantiles := range []func(s *Stream) []float64{
+		func(s *Stream) []float64 { return s.Quantiles(0.5, 2) },
+		func(s *Stream) []float64 { return s.QuantilesApprox(100, math.NaN()) },
+	} {
+		stream, _ := FromJSONLines(strings.NewReader("1\n2\n3"), 0.0)
+		if q := quantiles(stream); q != nil || stream.Err() == nil {
+			t.Errorf("expected an invalid quantile to fail, got %v, %v", q, stream.Err())
+		}
+		if q := stream.Quantiles(0.5); fmt.Sprint(q) != "[2]" {
+			t.Errorf("expected the source to be left unread, got %v, %v", q, stream.Err())
+		}
+	}
+}
+
+func TestQuantilesApprox(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	stream, _ := GenN(100000, func(i int) float64 {
+		return rnd.ExpFloat64() * 100
+	})
+	q := stream.QuantilesApprox(100, 0.5, 0.95, 0.99)
+	stream.Reset()
+	exact := stream.Quantiles(0.5, 0.95, 0.99)
+	for i := range q {
+		if math.Abs(q[i]-exact[i])/exact[i] > 0.01 {
+			t.Errorf("quantile %d: approx %v, exact %v", i, q[i], exact[i])
+		}
+	}
+	fmt.Printf("%s: approx %v, exact %v\n", t.Name(), q, exact)
+}
+
+func TestTDigestMerge(t *testing.T) {
+	a, b := NewTDigest(100), NewTDigest(100)
+	for i := 0; i < 1000; i++ {
+		a.Add(float64(i))
+		b.Add(float64(i + 1000))
+	}
+	a.Merge(b)
+	if a.Count() != 2000 {
+		t.Errorf("expected 2000 values, got %d", a.Count())
+	}
+	if m := a.Quantile(0.5); math.Abs(m-1000) > 10 {
+		t.Errorf("expected median about 1000, got %v", m)
+	}
+	if a.Quantile(0) != 0 || a.Quantile(1) != 1999 {
+		t.Errorf("unexpected min %v max %v", a.Quantile(0), a.Quantile(1))
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	stream, _ := Floats(1, 5, 10, 12, 50, 99, 100, 250)
+	h := stream.Histogram([]float64{10, 50, 100})
+	if fmt.Sprint(h.Counts) != "[2 2 2 2]" {
+		t.Errorf("unexpected counts %v", h.Counts)
+	}
+	stream.Reset()
+	h = stream.HistogramAuto(5)
+	if len(h.Bounds) != 4 || math.Abs(h.Bounds[0]-50.8) > 1e-9 || fmt.Sprint(h.Counts) != "[5 2 0 0 1]" {
+		t.Errorf("unexpected histogram %v", h)
+	}
+	stream.Reset()
+	if h := stream.Histogram([]float64{2, 1}); h.Counts != nil || stream.Err() == nil {
+		t.Errorf("expected error, got %v", h)
+	}
+	fmt.Println(t.Name()+":", h)
+}
@@ -0,0 +1,538 @@
+package stream
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QueryError is an error in a query, Pos is the byte offset in the query where it occurred.
+// The message reports the column in characters.
+type QueryError struct {
+	Query string
+	Pos   int
+	Msg   string
+}
+
+func (e *QueryError) Error() string {
+	col := utf8.RuneCountInString(e.Query[:e.Pos])
+	return fmt.Sprintf("query: column %d: %s\n\t%s\n\t%s^", col+1, e.Msg, e.Query, strings.Repeat(" ", col))
+}
+
+// Query is a parsed query, it can be applied to several streams. A query is a chain of stages separated by |:
+//
+//	where <condition>                  keep the elements matching the condition
+//	sort by <path> [asc|desc], ...     sort the elements by one or more fields
+//	limit <n>, skip <n>                keep or drop the first n elements
+//	select <path> [as <name>], ...     map the elements to a map[string]interface{} of some fields
+//
+// A condition compares a field path with a value: ==, !=, <, <=, >, >= or ~ for a regular expression, and
+// combines comparisons with and, or, not and parentheses. Values are numbers, strings in double or single quotes,
+// true and false. Paths are those of Pluck, so they read struct fields and map keys, except that a struct field is
+// matched case-insensitively when no field has the exact name. For example:
+//
+//	where age > 20 and name ~ "^K" | sort by age desc | limit 5 | select name, age
+type Query struct {
+	text   string
+	stages []queryStage
+}
+
+type queryStage struct {
+	kind  string
+	pos   int
+	cond  *queryCond
+	keys  []sortKey
+	n     int
+	items []selectItem
+}
+
+// queryCond is a node of a condition, op is "and", "or", "not" or a comparison operator.
+type queryCond struct {
+	op          string
+	pos         int
+	left, right *queryCond
+	path        string
+	value       interface{}
+}
+
+type sortKey struct {
+	path string
+	pos  int
+	desc bool
+}
+
+type selectItem struct {
+	path string
+	pos  int
+	name string
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokNumber
+	tokOperator
+	tokComma
+	tokPipe
+	tokLParen
+	tokRParen
+)
+
+type queryToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// ParseQuery parses a query, see Query. A syntax error is a *QueryError.
+func ParseQuery(text string) (*Query, error) {
+	tokens, err := lexQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{text: text, tokens: tokens}
+	q := &Query{text: text}
+	for {
+		stage, err := p.stage()
+		if err != nil {
+			return nil, err
+		}
+		q.stages = append(q.stages, stage)
+		if p.peek().kind == tokEOF {
+			return q, nil
+		}
+		if _, err := p.expect(tokPipe, "| or the end of the query"); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func lexQuery(text string) ([]queryToken, error) {
+	tokens := make([]queryToken, 0)
+	for i := 0; i < len(text); {
+		c := text[i]
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == ',':
+			tokens = append(tokens, queryToken{kind: tokComma, text: ",", pos: i})
+			i++
+		case c == '|':
+			tokens = append(tokens, queryToken{kind: tokPipe, text: "|", pos: i})
+			i++
+		case c == '(':
+			tokens = append(tokens, queryToken{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, queryToken{kind: tokRParen, text: ")", pos: i})
+			i++
+		case strings.ContainsRune("=!<>~", rune(c)):
+			end := i + 1
+			if end < len(text) && text[end] == '=' && c != '~' {
+				end++
+			}
+			op := text[i:end]
+			if op == "!" {
+				return nil, &QueryError{Query: text, Pos: i, Msg: "unexpected !, did you mean !="}
+			}
+			if op == "=" {
+				op = "=="
+			}
+			tokens = append(tokens, queryToken{kind: tokOperator, text: op, pos: i})
+			i = end
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(text) && text[end] != c {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(text) {
+				return nil, &QueryError{Query: text, Pos: i, Msg: "unterminated string"}
+			}
+			raw := text[i : end+1]
+			if c == '\'' {
+				raw = `"` + strings.ReplaceAll(strings.ReplaceAll(raw[1:len(raw)-1], `\'`, "'"), `"`, `\"`) + `"`
+			}
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return nil, &QueryError{Query: text, Pos: i, Msg: "invalid string " + text[i:end+1]}
+			}
+			tokens = append(tokens, queryToken{kind: tokString, text: s, pos: i})
+			i = end + 1
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(text) && strings.ContainsRune("0123456789.eE+-", rune(text[end])) {
+				end++
+			}
+			tokens = append(tokens, queryToken{kind: tokNumber, text: text[i:end], pos: i})
+			i = end
+		case c == '_' || unicode.IsLetter(r):
+			end := i + size
+			for end < len(text) {
+				if text[end] == '[' {
+					closing := strings.IndexByte(text[end:], ']')
+					if closing < 0 {
+						return nil, &QueryError{Query: text, Pos: end, Msg: "unclosed ["}
+					}
+					end += closing + 1
+				} else if d, n := utf8.DecodeRuneInString(text[end:]); d == '_' || d == '.' || unicode.IsLetter(d) || unicode.IsDigit(d) {
+					end += n
+				} else {
+					break
+				}
+			}
+			tokens = append(tokens, queryToken{kind: tokWord, text: text[i:end], pos: i})
+			i = end
+		default:
+			return nil, &QueryError{Query: text, Pos: i, Msg: fmt.Sprintf("unexpected %q", r)}
+		}
+	}
+	return append(tokens, queryToken{kind: tokEOF, pos: len(text)}), nil
+}
+
+type queryParser struct {
+	text   string
+	tokens []queryToken
+	i      int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.i]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *queryParser) errorf(t queryToken, format string, args ...interface{}) error {
+	return &QueryError{Query: p.text, Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// describe names a token for the error messages.
+func describe(t queryToken) string {
+	switch t.kind {
+	case tokEOF:
+		return "the end of the query"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return t.text
+}
+
+func (p *queryParser) expect(kind tokenKind, what string) (queryToken, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, p.errorf(t, "expected %s, found %s", what, describe(t))
+	}
+	return t, nil
+}
+
+// keyword consumes the next token if it is the given keyword.
+func (p *queryParser) keyword(word string) bool {
+	if t := p.peek(); t.kind == tokWord && strings.EqualFold(t.text, word) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) stage() (queryStage, error) {
+	t, err := p.expect(tokWord, "where, sort, limit, skip or select")
+	if err != nil {
+		return queryStage{}, err
+	}
+	stage := queryStage{kind: strings.ToLower(t.text), pos: t.pos}
+	switch stage.kind {
+	case "where":
+		stage.cond, err = p.or()
+	case "sort":
+		if !p.keyword("by") {
+			return stage, p.errorf(p.peek(), "expected by after sort, found %s", describe(p.peek()))
+		}
+		for {
+			var path queryToken
+			if path, err = p.path(); err != nil {
+				return stage, err
+			}
+			key := sortKey{path: path.text, pos: path.pos}
+			if p.keyword("desc") {
+				key.desc = true
+			} else {
+				p.keyword("asc")
+			}
+			stage.keys = append(stage.keys, key)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	case "limit", "skip":
+		n, err := p.expect(tokNumber, "a number")
+		if err != nil {
+			return stage, err
+		}
+		if stage.n, err = strconv.Atoi(n.text); err != nil || stage.n < 0 {
+			return stage, p.errorf(n, "expected a positive integer, found %s", n.text)
+		}
+	case "select":
+		for {
+			var path queryToken
+			if path, err = p.path(); err != nil {
+				return stage, err
+			}
+			item := selectItem{path: path.text, pos: path.pos, name: path.text}
+			if p.keyword("as") {
+				name, err := p.expect(tokWord, "a name after as")
+				if err != nil {
+					return stage, err
+				}
+				item.name = name.text
+			}
+			stage.items = append(stage.items, item)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	default:
+		return stage, p.errorf(t, "expected where, sort, limit, skip or select, found %s", t.text)
+	}
+	return stage, err
+}
+
+func (p *queryParser) path() (queryToken, error) {
+	t, err := p.expect(tokWord, "a field path")
+	if err == nil && isQueryKeyword(t.text) {
+		err = p.errorf(t, "expected a field path, found %s", t.text)
+	}
+	return t, err
+}
+
+func isQueryKeyword(word string) bool {
+	switch strings.ToLower(word) {
+	case "where", "sort", "by", "asc", "desc", "limit", "skip", "select", "as", "and", "or", "not", "true", "false":
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) or() (*queryCond, error) {
+	left, err := p.and()
+	for err == nil && p.peek().kind == tokWord && strings.EqualFold(p.peek().text, "or") {
+		t := p.next()
+		var right *queryCond
+		if right, err = p.and(); err == nil {
+			left = &queryCond{op: "or", pos: t.pos, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) and() (*queryCond, error) {
+	left, err := p.unary()
+	for err == nil && p.peek().kind == tokWord && strings.EqualFold(p.peek().text, "and") {
+		t := p.next()
+		var right *queryCond
+		if right, err = p.unary(); err == nil {
+			left = &queryCond{op: "and", pos: t.pos, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *queryParser) unary() (*queryCond, error) {
+	t := p.peek()
+	if p.keyword("not") {
+		cond, err := p.unary()
+		return &queryCond{op: "not", pos: t.pos, left: cond}, err
+	}
+	if t.kind == tokLParen {
+		p.next()
+		cond, err := p.or()
+		if err == nil {
+			_, err = p.expect(tokRParen, ")")
+		}
+		return cond, err
+	}
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	op, err := p.expect(tokOperator, "a comparison operator after "+path.text)
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.value(op)
+	return &queryCond{op: op.text, pos: path.pos, path: path.text, value: value}, err
+}
+
+func (p *queryParser) value(op queryToken) (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return t.text, nil
+	case tokNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %s", t.text)
+		}
+		return f, nil
+	case tokWord:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return nil, p.errorf(t, "expected a value after %s, found %s", op.text, describe(t))
+}
+
+// Query operation. Parse a query and apply it, see Query. An error in the query fails the stream.
+func (s *Stream) Query(text string) *Stream {
+	q, err := ParseQuery(text)
+	if err != nil {
+		s.failOp(err)
+		return s
+	}
+	return q.Apply(s)
+}
+
+// Apply adds the operations of the query to a stream. The field paths and the values compared with them are
+// checked against the element type of the stream when it is known, an error fails the stream with a *QueryError.
+func (q *Query) Apply(s *Stream) *Stream {
+	for _, stage := range q.stages {
+		if err := q.apply(s, stage); err != nil {
+			s.failOp(err)
+			return s
+		}
+	}
+	return s
+}
+
+func (q *Query) errorAt(pos int, err error) error {
+	return &QueryError{Query: q.text, Pos: pos, Msg: strings.TrimPrefix(err.Error(), "stream: ")}
+}
+
+func (q *Query) apply(s *Stream, stage queryStage) error {
+	switch stage.kind {
+	case "where":
+		match, err := q.compileCond(s, stage.cond)
+		if err != nil {
+			return err
+		}
+		fn := reflect.MakeFunc(s.elemFuncType(reflect.TypeOf(true)), func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(match(args[0].Interface()))}
+		})
+		s.Filter(fn.Interface())
+	case "sort":
+		getters := make([]func(it interface{}) reflect.Value, len(stage.keys))
+		for i, key := range stage.keys {
+			get, _, err := s.field(key.path, true)
+			if err != nil {
+				return q.errorAt(key.pos, err)
+			}
+			getters[i] = get
+		}
+		in := s.elemFuncType().In(0)
+		typ := reflect.FuncOf([]reflect.Type{in, in}, []reflect.Type{reflect.TypeOf(true)}, false)
+		fn := reflect.MakeFunc(typ, func(args []reflect.Value) []reflect.Value {
+			for i, get := range getters {
+				a, b := get(args[0].Interface()), get(args[1].Interface())
+				if stage.keys[i].desc {
+					a, b = b, a
+				}
+				if lessField(a, b) {
+					return []reflect.Value{reflect.ValueOf(true)}
+				}
+				if lessField(b, a) {
+					break
+				}
+			}
+			return []reflect.Value{reflect.ValueOf(false)}
+		})
+		s.Sort(fn.Interface())
+	case "limit":
+		s.Limit(stage.n)
+	case "skip":
+		s.Skip(stage.n)
+	case "select":
+		getters := make([]func(it interface{}) reflect.Value, len(stage.items))
+		for i, item := range stage.items {
+			get, _, err := s.field(item.path, true)
+			if err != nil {
+				return q.errorAt(item.pos, err)
+			}
+			getters[i] = get
+		}
+		s.Map(reflect.MakeFunc(s.elemFuncType(reflect.TypeOf(map[string]interface{}{})),
+			func(args []reflect.Value) []reflect.Value {
+				row := make(map[string]interface{}, len(getters))
+				for i, get := range getters {
+					var value interface{}
+					if v := get(args[0].Interface()); v.IsValid() {
+						value = v.Interface()
+					}
+					row[stage.items[i].name] = value
+				}
+				return []reflect.Value{reflect.ValueOf(row)}
+			}).Interface())
+	}
+	return nil
+}
+
+// compileCond returns a function evaluating a condition on an element.
+func (q *Query) compileCond(s *Stream, cond *queryCond) (func(it interface{}) bool, error) {
+	switch cond.op {
+	case "and", "or":
+		left, err := q.compileCond(s, cond.left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := q.compileCond(s, cond.right)
+		if err != nil {
+			return nil, err
+		}
+		if cond.op == "and" {
+			return func(it interface{}) bool { return left(it) && right(it) }, nil
+		}
+		return func(it interface{}) bool { return left(it) || right(it) }, nil
+	case "not":
+		inner, err := q.compileCond(s, cond.left)
+		if err != nil {
+			return nil, err
+		}
+		return func(it interface{}) bool { return !inner(it) }, nil
+	}
+	get, typ, err := s.field(cond.path, true)
+	if err != nil {
+		return nil, q.errorAt(cond.pos, err)
+	}
+	match, err := fieldMatcher(typ, cond.op, cond.value)
+	if err != nil {
+		return nil, q.errorAt(cond.pos, fmt.Errorf("%s: %w", cond.path, err))
+	}
+	return func(it interface{}) bool {
+		ok, err := match(get(it))
+		if err != nil {
+			s.fail(fmt.Errorf("query: %s: %w", cond.path, err))
+		}
+		return ok
+	}, nil
+}
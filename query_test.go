@@ -0,0 +1,120 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	stream, _ := New(createEmployees())
+	var rows []map[string]interface{}
+	err := stream.Query(`where age > 20 and name ~ "^K" | sort by age desc | limit 5 | select name, age`).ToSlice(&rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(rows) != "[map[age:45 name:King] map[age:31 name:Kate]]" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+	fmt.Println(t.Name()+":", rows)
+}
+
+func TestQueryConditions(t *testing.T) {
+	cases := map[string]string{
+		`where age >= 27 and age <= 31`:                     "[Kate Lucy]",
+		`where not (age < 30 or dept.name == "dev")`:        "[]",
+		`where dept.name = 'sales' or labels[site] ~ "^b"`:  "[Tom King]",
+		`where tags[0] != "go" or age > 40 | skip 1`:        "[King]",
+		`where NAME ~ "^L" OR Age > 40 | sort by name desc`: "[Lucy King]",
+	}
+	for query, expected := range cases {
+		stream, _ := New(createEmployees())
+		var names []string
+		err := stream.Query(query).Pluck("Name").ToSlice(&names)
+		if err != nil || fmt.Sprint(names) != expected {
+			t.Errorf("%s: expected %s, got %v, %v", query, expected, names, err)
+		}
+	}
+}
+
+func TestQueryMaps(t *testing.T) {
+	stream, _ := New([]map[string]interface{}{
+		{"city": "Paris", "population": 2.1, "country": "FR"},
+		{"city": "Lyon", "population": 0.5, "country": "FR"},
+		{"city": "Berlin", "population": 3.6, "country": "DE"},
+	})
+	var rows []map[string]interface{}
+	err := stream.Query(`where population > 1 | sort by country, city | select city as name`).ToSlice(&rows)
+	if err != nil || fmt.Sprint(rows) != "[map[name:Berlin] map[name:Paris]]" {
+		t.Errorf("unexpected rows %v, %v", rows, err)
+	}
+}
+
+func TestQueryUnicode(t *testing.T) {
+	stream, _ := New([]map[string]interface{}{{"größe": 3, "名前": "a"}, {"größe": 1, "名前": "b"}})
+	var names []interface{}
+	err := stream.Query(`where größe > 2 | select 名前`).Pluck("名前").ToSlice(&names)
+	if err != nil || fmt.Sprint(names) != "[a]" {
+		t.Errorf("unexpected names %v, %v", names, err)
+	}
+
+	_, err = ParseQuery(`where größe >`)
+	if err == nil || !strings.HasSuffix(err.Error(), "\twhere größe >\n\t             ^") {
+		t.Errorf("expected the caret under the end of the query, got %v", err)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	cases := map[string]string{
+		`where age >`:                   "column 12: expected a value after >, found the end of the query",
+		`where age > 20 limit 5`:        "column 16: expected | or the end of the query, found limit",
+		`sort age`:                      "column 6: expected by after sort, found age",
+		`where name ~ "^K`:              "column 14: unterminated string",
+		`limit -1`:                      "column 7: expected a positive integer, found -1",
+		`group by age`:                  "column 1: expected where, sort, limit, skip or select, found group",
+		`where (age > 20`:               "column 16: expected ), found the end of the query",
+		`where age ! 20`:                "column 11: unexpected !",
+		`select name as`:                "column 15: expected a name after as",
+		`where (age > 20) and and < 20`: "column 22: expected a field path, found and",
+		`where städte > 1 and §`:        "column 22: unexpected '§'",
+		`where name == "café" and`:      "column 25: expected a field path, found the end",
+	}
+	for query, msg := range cases {
+		_, err := ParseQuery(query)
+		var qe *QueryError
+		if !errors.As(err, &qe) || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: expected %q, got %v", query, msg, err)
+		}
+	}
+}
+
+func TestQueryTypeErrors(t *testing.T) {
+	cases := map[string]string{
+		`where agee > 20`:             "column 7: field path \"agee\": stream.employee has no field agee",
+		`where age > "20"`:            "column 7: age: can't compare int with string",
+		`where name ~ 1`:              "column 7: name: the pattern of ~ must be a string",
+		`where age > 1 | sort by foo`: "column 25: field path \"foo\"",
+		`select name, tags.x`:         "column 14: field path \"tags.x\"",
+	}
+	for query, msg := range cases {
+		stream, _ := New(createEmployees())
+		err := stream.Query(query).ToSlice(&[]interface{}{})
+		var qe *QueryError
+		if !errors.As(err, &qe) || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: expected %q, got %v", query, msg, err)
+		}
+	}
+
+	// a parsed query is only checked when applied
+	q, err := ParseQuery(`where age > 20 and nme == "x"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, _ := New(createEmployees())
+	err = q.Apply(stream).ToSlice(&[]employee{})
+	if err == nil || !strings.Contains(err.Error(), "column 20") {
+		t.Errorf("expected an error at column 20, got %v", err)
+	}
+	fmt.Println(t.Name()+":", err)
+}
@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// ReduceCombine operation. Reduce the elements in partitions reduced in parallel, each from identity with
+// accumulator, then combine the results of the partitions in order with combiner.
+// identity must be an identity for combiner, and combiner must be associative and compatible with accumulator:
+// combiner(r, accumulator(identity, o)) == accumulator(r, o).
+// accumulator is called from several goroutines at once, so it must be safe for concurrent use: it must not
+// mutate identity or state shared between calls. combiner is called from the calling goroutine only.
+// accumulator: func(r R, o T) R, combiner: func(r1, r2 R) R
+func (s *Stream) ReduceCombine(identity interface{}, accumulator interface{}, combiner interface{}) interface{} {
+	accValue := reflect.ValueOf(accumulator)
+	combValue := reflect.ValueOf(combiner)
+	data := s.collect()
+	n := runtime.GOMAXPROCS(0)
+	if n > len(data) {
+		n = len(data)
+	}
+	partials := make([]interface{}, n)
+	var wg sync.WaitGroup
+	for p := 0; p < n; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			result := identity
+			for _, it := range data[p*len(data)/n : (p+1)*len(data)/n] {
+				result = call(accValue, result, it)[0].Interface()
+			}
+			partials[p] = result
+		}(p)
+	}
+	wg.Wait()
+	result := identity
+	for _, partial := range partials {
+		result = call(combValue, result, partial)[0].Interface()
+	}
+	return result
+}
+
+// ReduceNoInit operation. Reduce the elements starting from the first one, the result is absent if there is none.
+// reduceFunc: func(r T, o T) T
+func (s *Stream) ReduceNoInit(reduceFunc interface{}) Optional {
+	funcValue := reflect.ValueOf(reduceFunc)
+	next, done := s.iterator()
+	defer done()
+	result, ok := next()
+	if !ok {
+		return Optional{}
+	}
+	for it, ok := next(); ok; it, ok = next() {
+		result = call(funcValue, result, it)[0].Interface()
+	}
+	return OptionalOf(result)
+}
+
+// scanArg is the argument of the scan operations, RunningReduce has no initial value.
+type scanArg struct {
+	init    interface{}
+	hasInit bool
+}
+
+// Scan operation. Reduce the elements as Reduce does, emitting the result after each element.
+// reduceFunc: func(r R, o T) R
+func (s *Stream) Scan(initValue interface{}, reduceFunc interface{}) *Stream {
+	funcValue := reflect.ValueOf(reduceFunc)
+	s.ops = append(s.ops, op{typ: "scan", fun: funcValue, arg: scanArg{init: initValue, hasInit: true}})
+	return s
+}
+
+// RunningReduce operation. Reduce the elements starting from the first one, emitting the first element
+// then the result after each of the others.
+// reduceFunc: func(r T, o T) T
+func (s *Stream) RunningReduce(reduceFunc interface{}) *Stream {
+	funcValue := reflect.ValueOf(reduceFunc)
+	s.ops = append(s.ops, op{typ: "scan", fun: funcValue, arg: scanArg{}})
+	return s
+}
+
+func scanStage(next iterator, op op) iterator {
+	arg := op.arg.(scanArg)
+	result, started := arg.init, arg.hasInit
+	return func() (interface{}, bool) {
+		it, ok := next()
+		if !ok {
+			return nil, false
+		}
+		if !started {
+			result, started = it, true
+		} else {
+			result = call(op.fun, result, it)[0].Interface()
+		}
+		return result, true
+	}
+}
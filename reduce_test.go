@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReduceCombine(t *testing.T) {
+	stream, _ := GenN(1000, func(i int) int { return i })
+	sum := stream.ReduceCombine(0, func(r int, o int) int {
+		return r + o
+	}, func(r1, r2 int) int {
+		return r1 + r2
+	})
+	if sum != 499500 {
+		t.Errorf("expected 499500, got %v", sum)
+	}
+
+	// the partitions are combined in order
+	stream, _ = Strings(strings.Split("the quick brown fox jumps over the lazy dog", " ")...)
+	joined := stream.ReduceCombine("", func(r string, o string) string {
+		return r + o[:1]
+	}, func(r1, r2 string) string {
+		return r1 + r2
+	})
+	if joined != "tqbfjotld" {
+		t.Errorf("expected the initials in order, got %v", joined)
+	}
+
+	stream, _ = Ints()
+	if r := stream.ReduceCombine(int64(7), func(r, o int64) int64 { return r + o },
+		func(r1, r2 int64) int64 { return r1 + r2 }); r != int64(7) {
+		t.Errorf("expected the identity for no element, got %v", r)
+	}
+	fmt.Println(t.Name()+":", sum, joined)
+}
+
+func TestReduceNoInit(t *testing.T) {
+	stream, _ := Ints(3, 9, 4)
+	max := stream.ReduceNoInit(func(r, o int64) int64 {
+		if o > r {
+			return o
+		}
+		return r
+	})
+	if !max.IsPresent() || max.Get() != int64(9) {
+		t.Errorf("expected 9, got %v", max.Get())
+	}
+
+	stream, _ = Ints()
+	if r := stream.ReduceNoInit(func(r, o int64) int64 { return r + o }); r.IsPresent() {
+		t.Errorf("expected no result, got %v", r.Get())
+	}
+}
+
+func TestScan(t *testing.T) {
+	stream, _ := Ints(1, 2, 3, 4)
+	var result []int64
+	stream.Scan(int64(10), func(r, o int64) int64 {
+		return r + o
+	}).ToSlice(&result)
+	if fmt.Sprint(result) != "[11 13 16 20]" {
+		t.Errorf("unexpected running sums %v", result)
+	}
+
+	stream, _ = Ints(1, 2, 3, 4)
+	var lines []string
+	stream.Scan("", func(r string, o int64) string {
+		return r + fmt.Sprint(o)
+	}).Limit(3).ToSlice(&lines)
+	if fmt.Sprint(lines) != "[1 12 123]" {
+		t.Errorf("unexpected running concatenations %v", lines)
+	}
+	fmt.Println(t.Name()+":", result, lines)
+}
+
+func TestRunningReduce(t *testing.T) {
+	stream, _ := Ints(3, 1, 4, 1, 5)
+	var result []int64
+	stream.RunningReduce(func(r, o int64) int64 {
+		if o > r {
+			return o
+		}
+		return r
+	}).ToSlice(&result)
+	if fmt.Sprint(result) != "[3 3 4 4 5]" {
+		t.Errorf("unexpected running maxima %v", result)
+	}
+}
@@ -0,0 +1,160 @@
+package stream
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+)
+
+// errorHandler handles the error of an element, it returns the element to emit instead, if any.
+type errorHandler func(it interface{}, err error) (interface{}, bool)
+
+// RetryPolicy decides how MapRetry retries an element. The wait before the n-th retry is
+// Backoff * Multiplier^(n-1), at most MaxBackoff, reduced by a random fraction up to Jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the number of calls for an element, including the first one, at least 1.
+	MaxAttempts int
+	// Backoff is the wait before the first retry.
+	Backoff time.Duration
+	// MaxBackoff caps the wait, 0 means no cap.
+	MaxBackoff time.Duration
+	// Multiplier grows the wait after each retry, 2 if it is less than 1.
+	Multiplier float64
+	// Jitter is the fraction of the wait that is random, between 0 and 1.
+	Jitter float64
+	// Retryable reports if an error is worth retrying, all errors are if it is nil.
+	Retryable func(err error) bool
+	// Rand is the source of the jitter, the default source of math/rand if it is nil.
+	Rand *rand.Rand
+}
+
+// wait returns the wait before the retry after the given attempt.
+func (p RetryPolicy) wait(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	wait := float64(p.Backoff)
+	for i := 1; i < attempt; i++ {
+		wait *= multiplier
+		if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		r := rand.Float64
+		if p.Rand != nil {
+			r = p.Rand.Float64
+		}
+		wait -= wait * p.Jitter * r()
+	}
+	return time.Duration(wait)
+}
+
+// MapRetry operation. Map one to one, calling mapFunc again as the policy allows when it fails. The last error
+// stops the stream and is reported by Err, unless it is handled by OnErrorResume, OnErrorSkip or OnErrorDeadLetter.
+// The waits use the clock of the stream, see WithClock.
+// mapFunc: func(o T1) (T2, error)
+func (s *Stream) MapRetry(mapFunc interface{}, policy RetryPolicy) *Stream {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	funcValue := reflect.ValueOf(mapFunc)
+	if funcValue.Kind() != reflect.Func || funcValue.Type().NumIn() != 1 || funcValue.Type().NumOut() != 2 ||
+		funcValue.Type().Out(1) != errorType {
+		s.failOp(fmt.Errorf("stream: MapRetry function must be like func(o T1) (T2, error), not %T", mapFunc))
+		return s
+	}
+	s.ops = append(s.ops, op{typ: "mapRetry", fun: funcValue, arg: policy})
+	return s
+}
+
+func mapRetryStage(next iterator, op op, s *Stream) iterator {
+	policy := op.arg.(RetryPolicy)
+	clock := s.getClock()
+	return func() (interface{}, bool) {
+		for {
+			it, ok := next()
+			if !ok {
+				return nil, false
+			}
+			var err error
+			for attempt := 1; ; attempt++ {
+				out := call(op.fun, it)
+				if err, _ = out[1].Interface().(error); err == nil {
+					return out[0].Interface(), true
+				}
+				if attempt >= policy.MaxAttempts || (policy.Retryable != nil && !policy.Retryable(err)) {
+					if attempt > 1 {
+						err = fmt.Errorf("after %d attempts: %w", attempt, err)
+					}
+					break
+				}
+				clock.Sleep(policy.wait(attempt))
+			}
+			if op.onErr == nil {
+				s.fail(err)
+				return nil, false
+			}
+			if it, emit := op.onErr(it, err); emit {
+				return it, true
+			}
+		}
+	}
+}
+
+// OnErrorResume operation. Replace the elements that failed in the operations before it, such as MapRetry
+// and MapAsync, with what fallbackFunc returns.
+// fallbackFunc: func(o T1, err error) T2
+func (s *Stream) OnErrorResume(fallbackFunc interface{}) *Stream {
+	funcValue := reflect.ValueOf(fallbackFunc)
+	if !isErrorFunc(funcValue, 1) {
+		s.failOp(fmt.Errorf("stream: OnErrorResume function must be like func(o T1, err error) T2, not %T", fallbackFunc))
+		return s
+	}
+	return s.onError(func(it interface{}, err error) (interface{}, bool) {
+		return call(funcValue, it, err)[0].Interface(), true
+	})
+}
+
+// OnErrorSkip operation. Drop the elements that failed in the operations before it, such as MapRetry and MapAsync.
+func (s *Stream) OnErrorSkip() *Stream {
+	return s.onError(func(it interface{}, err error) (interface{}, bool) {
+		return nil, false
+	})
+}
+
+// OnErrorDeadLetter operation. Pass the elements that failed in the operations before it, such as MapRetry
+// and MapAsync, to sinkFunc with their error, and drop them.
+// sinkFunc: func(o T, err error)
+func (s *Stream) OnErrorDeadLetter(sinkFunc interface{}) *Stream {
+	funcValue := reflect.ValueOf(sinkFunc)
+	if !isErrorFunc(funcValue, 0) {
+		s.failOp(fmt.Errorf("stream: OnErrorDeadLetter function must be like func(o T, err error), not %T", sinkFunc))
+		return s
+	}
+	return s.onError(func(it interface{}, err error) (interface{}, bool) {
+		call(funcValue, it, err)
+		return nil, false
+	})
+}
+
+// isErrorFunc reports if fun takes an element and an error, and returns numOut values.
+func isErrorFunc(fun reflect.Value, numOut int) bool {
+	return fun.Kind() == reflect.Func && fun.Type().NumIn() == 2 && fun.Type().In(1) == errorType &&
+		fun.Type().NumOut() == numOut
+}
+
+// onError sets the error handler of the operations before it that have none.
+func (s *Stream) onError(handler errorHandler) *Stream {
+	for i := range s.ops {
+		if s.ops[i].onErr == nil {
+			s.ops[i].onErr = handler
+		}
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky")
+
+// flaky returns a map function that fails the first failures calls for each element.
+func flaky(failures int) func(i int) (int, error) {
+	calls := make(map[int]int)
+	return func(i int) (int, error) {
+		calls[i]++
+		if calls[i] <= failures {
+			return 0, errFlaky
+		}
+		return i * 10, nil
+	}
+}
+
+func TestMapRetry(t *testing.T) {
+	clock := &fakeClock{}
+	stream, _ := GenN(3, func(i int) int { return i })
+	var result []int
+	err := stream.WithClock(clock).MapRetry(flaky(2), RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     100 * time.Millisecond,
+	}).ToSlice(&result)
+	if err != nil || fmt.Sprint(result) != "[0 10 20]" {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+	// each element waits 100ms then 200ms
+	if clock.slept != 900*time.Millisecond {
+		t.Errorf("expected 900ms of backoff, got %v", clock.slept)
+	}
+	fmt.Println(t.Name()+":", result, clock.slept)
+}
+
+func TestMapRetryExhausted(t *testing.T) {
+	clock := &fakeClock{}
+	stream, _ := GenN(3, func(i int) int { return i })
+	var result []int
+	err := stream.WithClock(clock).MapRetry(flaky(5), RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     time.Second,
+		MaxBackoff:  1500 * time.Millisecond,
+	}).ToSlice(&result)
+	if !errors.Is(err, errFlaky) || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("expected the last error after 3 attempts, got %v", err)
+	}
+	if clock.slept != 2500*time.Millisecond {
+		t.Errorf("expected 2.5s of capped backoff, got %v", clock.slept)
+	}
+}
+
+func TestMapRetryNotRetryable(t *testing.T) {
+	clock := &fakeClock{}
+	stream, _ := GenN(1, func(i int) int { return i })
+	err := stream.WithClock(clock).MapRetry(flaky(1), RetryPolicy{
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+		Retryable:   func(err error) bool { return err != errFlaky },
+	}).ToSlice(&[]int{})
+	if err != errFlaky || clock.slept != 0 {
+		t.Errorf("expected no retry, got %v after %v", err, clock.slept)
+	}
+}
+
+func TestMapRetryInvalidFunc(t *testing.T) {
+	for _, mapFunc := range []interface{}{func(i int) int { return i }, func(i int) (int, bool) { return i, true }, nil} {
+		stream, _ := GenN(1, func(i int) int { return i })
+		err := stream.MapRetry(mapFunc, RetryPolicy{MaxAttempts: 2}).ToSlice(&[]int{})
+		if err == nil || !strings.Contains(err.Error(), "must be like func(o T1) (T2, error)") {
+			t.Errorf("%T: expected an invalid function error, got %v", mapFunc, err)
+		}
+	}
+}
+
+func TestOnErrorInvalidFunc(t *testing.T) {
+	fail := func(i int) (int, error) { return 0, errors.New("fail") }
+	stream, _ := GenN(1, func(i int) int { return i })
+	err := stream.MapRetry(fail, RetryPolicy{}).OnErrorResume(func(i int) int { return i }).ToSlice(&[]int{})
+	if err == nil || !strings.Contains(err.Error(), "OnErrorResume function must be like") {
+		t.Errorf("expected an invalid function error, got %v", err)
+	}
+	stream, _ = GenN(1, func(i int) int { return i })
+	err = stream.MapAsync(fail, 2).OnErrorDeadLetter(func(i int, err error) bool { return true }).ToSlice(&[]int{})
+	if err == nil || !strings.Contains(err.Error(), "OnErrorDeadLetter function must be like") {
+		t.Errorf("expected an invalid function error, got %v", err)
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	policy := RetryPolicy{Backoff: time.Second, Multiplier: 3, Jitter: 0.5}
+	for attempt, max := range []time.Duration{time.Second, 3 * time.Second, 9 * time.Second} {
+		wait := policy.wait(attempt + 1)
+		if wait > max || wait < max/2 {
+			t.Errorf("wait %v of attempt %d is not in [%v, %v]", wait, attempt+1, max/2, max)
+		}
+	}
+}
+
+func TestOnErrorResume(t *testing.T) {
+	stream, _ := GenN(4, func(i int) int { return i })
+	var result []int
+	err := stream.MapRetry(func(i int) (int, error) {
+		if i%2 == 1 {
+			return 0, errFlaky
+		}
+		return i, nil
+	}, RetryPolicy{}).OnErrorResume(func(i int, err error) int {
+		return -i
+	}).ToSlice(&result)
+	if err != nil || fmt.Sprint(result) != "[0 -1 2 -3]" {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+	fmt.Println(t.Name()+":", result)
+}
+
+func TestOnErrorSkip(t *testing.T) {
+	stream, _ := GenN(4, func(i int) int { return i })
+	var result []int
+	err := stream.MapAsync(func(i int) (int, error) {
+		if i%2 == 1 {
+			return 0, errFlaky
+		}
+		return i, nil
+	}, 2).OnErrorSkip().ToSlice(&result)
+	if err != nil || fmt.Sprint(result) != "[0 2]" {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+}
+
+func TestOnErrorDeadLetter(t *testing.T) {
+	stream, _ := GenN(5, func(i int) int { return i })
+	var result, dead []int
+	err := stream.MapRetry(func(i int) (int, error) {
+		if i%2 == 1 {
+			return 0, fmt.Errorf("odd %d", i)
+		}
+		return i, nil
+	}, RetryPolicy{MaxAttempts: 2}).OnErrorDeadLetter(func(i int, err error) {
+		dead = append(dead, i)
+		if !strings.HasPrefix(err.Error(), "after 2 attempts: odd") {
+			t.Errorf("unexpected error %v", err)
+		}
+	}).Map(func(i int) int {
+		return i * 10
+	}).ToSlice(&result)
+	if err != nil || fmt.Sprint(result) != "[0 20 40]" || fmt.Sprint(dead) != "[1 3]" {
+		t.Errorf("unexpected result %v, dead letters %v, %v", result, dead, err)
+	}
+	fmt.Println(t.Name()+":", result, dead)
+}
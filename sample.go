@@ -0,0 +1,125 @@
+package stream
+
+import (
+	"math/rand"
+	"reflect"
+)
+
+// sampleArg is the argument of the sampling operations, a nil rng uses the default source of math/rand.
+type sampleArg struct {
+	n   int
+	p   float64
+	rng *rand.Rand
+}
+
+func (arg sampleArg) intn(n int) int {
+	if arg.rng == nil {
+		return rand.Intn(n)
+	}
+	return arg.rng.Intn(n)
+}
+
+func (arg sampleArg) float64() float64 {
+	if arg.rng == nil {
+		return rand.Float64()
+	}
+	return arg.rng.Float64()
+}
+
+// Sample operation. Keep n elements chosen at random with reservoir sampling, in memory proportional to n
+// whatever the number of elements. rng may be nil to use the default source of math/rand.
+func (s *Stream) Sample(n int, rng *rand.Rand) *Stream {
+	s.ops = append(s.ops, op{typ: "sample", arg: sampleArg{n: n, rng: rng}})
+	return s
+}
+
+// SampleFraction operation. Keep each element with the probability p.
+// rng may be nil to use the default source of math/rand.
+func (s *Stream) SampleFraction(p float64, rng *rand.Rand) *Stream {
+	s.ops = append(s.ops, op{typ: "sampleFraction", arg: sampleArg{p: p, rng: rng}})
+	return s
+}
+
+// Shuffle operation. Put the elements in random order. rng may be nil to use the default source of math/rand.
+func (s *Stream) Shuffle(rng *rand.Rand) *Stream {
+	s.ops = append(s.ops, op{typ: "shuffle", arg: sampleArg{rng: rng}})
+	return s
+}
+
+// StratifiedSample operation. Keep n elements chosen at random for each key, the samples are in the order
+// the keys first appear. rng may be nil to use the default source of math/rand.
+// keyFunc: func(o T) K
+func (s *Stream) StratifiedSample(keyFunc interface{}, n int, rng *rand.Rand) *Stream {
+	funcValue := reflect.ValueOf(keyFunc)
+	s.ops = append(s.ops, op{typ: "stratifiedSample", fun: funcValue, arg: sampleArg{n: n, rng: rng}})
+	return s
+}
+
+// reservoir keeps a uniform random sample of the elements added to it.
+type reservoir struct {
+	data []interface{}
+	seen int
+}
+
+func (r *reservoir) add(it interface{}, arg sampleArg) {
+	r.seen++
+	if len(r.data) < arg.n {
+		r.data = append(r.data, it)
+	} else if j := arg.intn(r.seen); j < arg.n {
+		r.data[j] = it
+	}
+}
+
+func sampleStage(next iterator, op op) iterator {
+	arg := op.arg.(sampleArg)
+	r := &reservoir{data: make([]interface{}, 0)}
+	if arg.n > 0 {
+		for it, ok := next(); ok; it, ok = next() {
+			r.add(it, arg)
+		}
+	}
+	return sliceIterator(r.data)
+}
+
+func sampleFractionStage(next iterator, op op) iterator {
+	arg := op.arg.(sampleArg)
+	return func() (interface{}, bool) {
+		for {
+			it, ok := next()
+			if !ok || arg.float64() < arg.p {
+				return it, ok
+			}
+		}
+	}
+}
+
+func shuffleStage(next iterator, op op) iterator {
+	arg := op.arg.(sampleArg)
+	data := drain(next)
+	for i := len(data) - 1; i > 0; i-- {
+		j := arg.intn(i + 1)
+		data[i], data[j] = data[j], data[i]
+	}
+	return sliceIterator(data)
+}
+
+func stratifiedSampleStage(next iterator, op op) iterator {
+	arg := op.arg.(sampleArg)
+	keys := make([]interface{}, 0)
+	strata := make(map[interface{}]*reservoir)
+	for it, ok := next(); ok; it, ok = next() {
+		key := call(op.fun, it)[0].Interface()
+		r, found := strata[key]
+		if !found {
+			r = &reservoir{}
+			strata[key] = r
+			keys = append(keys, key)
+		}
+		r.add(it, arg)
+	}
+	data := make([]interface{}, 0)
+	for _, key := range keys {
+		data = append(data, strata[key].data...)
+	}
+	return sliceIterator(data)
+}
@@ -0,0 +1,63 @@
+package stream
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	stream, _ := GenN(1000, func(i int) int { return i })
+	var first, second []int
+	stream.Sample(5, rand.New(rand.NewSource(1))).ToSlice(&first)
+	stream.Reset()
+	stream.Sample(5, rand.New(rand.NewSource(1))).ToSlice(&second)
+	if len(first) != 5 || fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("expected the same 5 elements, got %v and %v", first, second)
+	}
+
+	students := createStudents()
+	stream, _ = New(students)
+	if n := stream.Sample(20, nil).Count(); n != 10 {
+		t.Errorf("expected all 10 students, got %d", n)
+	}
+	fmt.Printf("\t%v\n", first)
+}
+
+func TestSampleFraction(t *testing.T) {
+	stream, _ := GenN(10000, func(i int) int { return i })
+	n := stream.SampleFraction(0.1, rand.New(rand.NewSource(1))).Count()
+	if n < 900 || n > 1100 {
+		t.Errorf("expected about 1000 elements, got %d", n)
+	}
+	fmt.Println(t.Name()+":", n)
+}
+
+func TestShuffle(t *testing.T) {
+	stream, _ := Ints(1, 2, 3, 4, 5, 6, 7, 8)
+	var shuffled []int64
+	stream.Shuffle(rand.New(rand.NewSource(1))).ToSlice(&shuffled)
+	stream.Reset()
+	if fmt.Sprint(shuffled) == "[1 2 3 4 5 6 7 8]" || stream.Sum() != int64(36) {
+		t.Errorf("unexpected shuffle %v", shuffled)
+	}
+	fmt.Println(t.Name()+":", shuffled)
+}
+
+func TestStratifiedSample(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	students := createStudents()
+	stream, _ := New(students)
+	group := stream.StratifiedSample(func(s student) bool {
+		return s.age > 20
+	}, 2, rand.New(rand.NewSource(1))).Group(func(s student) (bool, int) {
+		return s.age > 20, s.id
+	})
+	for k, ids := range group {
+		if len(ids) > 3 {
+			t.Errorf("expected at most 2 students with age > 20 %t, got %v", k, ids)
+		}
+	}
+	fmt.Printf("\t%v\n", group)
+}
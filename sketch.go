@@ -0,0 +1,359 @@
+package stream
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"reflect"
+	"sort"
+)
+
+// HyperLogLog estimates the number of distinct keys in a fixed memory of 2^precision bytes,
+// with a standard error of about 1.04/sqrt(2^precision). Sketches of partitions of the keys can be merged.
+type HyperLogLog struct {
+	precision uint
+	registers []uint8
+}
+
+// NewHyperLogLog create a HyperLogLog sketch, precision is between 4 and 18.
+func NewHyperLogLog(precision int) *HyperLogLog {
+	if precision < 4 {
+		precision = 4
+	}
+	if precision > 18 {
+		precision = 18
+	}
+	return &HyperLogLog{precision: uint(precision), registers: make([]uint8, 1<<uint(precision))}
+}
+
+// Add adds a key.
+func (h *HyperLogLog) Add(key interface{}) {
+	x := hashKey(key)
+	i := x >> (64 - h.precision)
+	rank := uint8(bits.LeadingZeros64(x<<h.precision|1<<(h.precision-1))) + 1
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+// Count returns the estimated number of distinct keys.
+func (h *HyperLogLog) Count() uint64 {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// Merge adds the keys of other, which must have the same precision.
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	if h.precision != other.precision {
+		return errors.New("hyperloglog: precisions differ")
+	}
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+	return nil
+}
+
+// Frequency is an estimated count of a key, the true count is between Count-Error and Count.
+type Frequency struct {
+	Key   interface{}
+	Count uint64
+	Error uint64
+}
+
+// SpaceSaving finds the most frequent keys with the Space-Saving algorithm, counting at most capacity keys.
+// A key more frequent than 1/capacity of all the keys is always found. Summaries of partitions can be merged.
+// A key that can't be a map key, such as a slice, is told apart from the others by its hash, as in HyperLogLog.
+type SpaceSaving struct {
+	capacity int
+	counters map[interface{}]*Frequency // by counterKey
+}
+
+// NewSpaceSaving create a Space-Saving summary counting at most capacity keys.
+func NewSpaceSaving(capacity int) *SpaceSaving {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &SpaceSaving{capacity: capacity, counters: make(map[interface{}]*Frequency)}
+}
+
+// Add adds a key.
+func (ss *SpaceSaving) Add(key interface{}) {
+	ss.add(key)
+}
+
+func (ss *SpaceSaving) add(key interface{}) {
+	k := counterKey(key)
+	if f, ok := ss.counters[k]; ok {
+		f.Count++
+		return
+	}
+	if len(ss.counters) < ss.capacity {
+		ss.counters[k] = &Frequency{Key: key, Count: 1}
+		return
+	}
+	min := ss.min()
+	delete(ss.counters, counterKey(min.Key))
+	ss.counters[k] = &Frequency{Key: key, Count: min.Count + 1, Error: min.Count}
+}
+
+// hashedKey is the hash of a key that can't be a map key.
+type hashedKey uint64
+
+// counterKey returns the key itself if it can be a map key, or else its hash.
+func counterKey(key interface{}) interface{} {
+	if key == nil || reflect.ValueOf(key).Comparable() {
+		return key
+	}
+	return hashedKey(hashKey(key))
+}
+
+func (ss *SpaceSaving) min() *Frequency {
+	var min *Frequency
+	for _, f := range ss.counters {
+		if min == nil || f.Count < min.Count {
+			min = f
+		}
+	}
+	return min
+}
+
+// Top returns the n most frequent keys, in descending order of count.
+func (ss *SpaceSaving) Top(n int) []Frequency {
+	top := make([]Frequency, 0, len(ss.counters))
+	for _, f := range ss.counters {
+		top = append(top, *f)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return fmt.Sprint(top[i].Key) < fmt.Sprint(top[j].Key)
+	})
+	if n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// Merge adds the keys of other. A key missing from a full summary may have been counted up to its minimum count,
+// which is added to the error.
+func (ss *SpaceSaving) Merge(other *SpaceSaving) {
+	merged := make(map[interface{}]*Frequency, len(ss.counters)+len(other.counters))
+	for _, summary := range []*SpaceSaving{ss, other} {
+		for k, f := range summary.counters {
+			if _, ok := merged[k]; ok {
+				continue
+			}
+			count, countErr := ss.estimate(k)
+			otherCount, otherErr := other.estimate(k)
+			merged[k] = &Frequency{Key: f.Key, Count: count + otherCount, Error: countErr + otherErr}
+		}
+	}
+	ss.counters = merged
+	if len(merged) > ss.capacity {
+		for _, f := range ss.Top(len(merged))[ss.capacity:] {
+			delete(ss.counters, counterKey(f.Key))
+		}
+	}
+}
+
+// estimate returns the count and error of a counter key, a key that isn't counted by a full summary
+// may have been counted up to its minimum count.
+func (ss *SpaceSaving) estimate(k interface{}) (uint64, uint64) {
+	if f, ok := ss.counters[k]; ok {
+		return f.Count, f.Error
+	}
+	if len(ss.counters) < ss.capacity {
+		return 0, 0
+	}
+	min := ss.min().Count
+	return min, min
+}
+
+// BloomFilter tests whether a key was added, with false positives but no false negatives.
+// Filters of partitions of the keys with the same size can be merged.
+type BloomFilter struct {
+	bits   []uint64
+	hashes int
+}
+
+// NewBloomFilter create a Bloom filter sized for n keys with the false positive rate p.
+func NewBloomFilter(n int, p float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BloomFilter{bits: make([]uint64, (int(m)+63)/64), hashes: k}
+}
+
+// Add adds a key, it returns true if the key may have been added before.
+func (b *BloomFilter) Add(key interface{}) bool {
+	found := true
+	m := uint64(len(b.bits) * 64)
+	h1, h2 := bloomHashes(key)
+	for i := 0; i < b.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			found = false
+			b.bits[bit/64] |= 1 << (bit % 64)
+		}
+	}
+	return found
+}
+
+// Contains returns true if the key may have been added, false if it certainly wasn't.
+func (b *BloomFilter) Contains(key interface{}) bool {
+	m := uint64(len(b.bits) * 64)
+	h1, h2 := bloomHashes(key)
+	for i := 0; i < b.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge adds the keys of other, which must have been created with the same size.
+func (b *BloomFilter) Merge(other *BloomFilter) error {
+	if len(b.bits) != len(other.bits) || b.hashes != other.hashes {
+		return errors.New("bloom filter: sizes differ")
+	}
+	for i, w := range other.bits {
+		b.bits[i] |= w
+	}
+	return nil
+}
+
+func bloomHashes(key interface{}) (uint64, uint64) {
+	h := hashKey(key)
+	return h, mix64(h) | 1
+}
+
+// hashKey hashes a key by its type and value.
+func hashKey(key interface{}) uint64 {
+	h := fnv.New64a()
+	switch k := key.(type) {
+	case string:
+		h.Write([]byte(k))
+	case []byte:
+		h.Write(k)
+	default:
+		v := reflect.ValueOf(key)
+		var buf [8]byte
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			binary.LittleEndian.PutUint64(buf[:], uint64(v.Int()))
+			h.Write(buf[:])
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			binary.LittleEndian.PutUint64(buf[:], v.Uint())
+			h.Write(buf[:])
+		default:
+			fmt.Fprintf(h, "%T:%v", key, key)
+		}
+	}
+	return mix64(h.Sum64())
+}
+
+// mix64 is the finalizer of splitmix64, it spreads the bits of a hash.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// CountDistinctApprox operation. Return the estimated number of distinct keys of the elements, counted with
+// a HyperLogLog sketch of the given precision. keyFunc may be nil to count distinct elements.
+// keyFunc: func(o T) K
+func (s *Stream) CountDistinctApprox(keyFunc interface{}, precision int) uint64 {
+	h := NewHyperLogLog(precision)
+	s.eachKey(keyFunc, h.Add)
+	return h.Count()
+}
+
+// TopFrequent operation. Return the k most frequent keys of the elements, counted with a Space-Saving summary
+// of 10*k keys. keyFunc may be nil to count the elements themselves.
+// keyFunc: func(o T) K
+func (s *Stream) TopFrequent(k int, keyFunc interface{}) []Frequency {
+	ss := NewSpaceSaving(10 * k)
+	s.eachKey(keyFunc, ss.Add)
+	return ss.Top(k)
+}
+
+// eachKey calls fn with the key of each element, without keeping the elements in memory.
+func (s *Stream) eachKey(keyFunc interface{}, fn func(key interface{})) {
+	funcValue := reflect.ValueOf(keyFunc)
+	next, done := s.iterator()
+	defer done()
+	for it, ok := next(); ok; it, ok = next() {
+		if keyFunc != nil {
+			it = call(funcValue, it)[0].Interface()
+		}
+		fn(it)
+	}
+}
+
+// bloomArg is the argument of the distinctApprox operation.
+type bloomArg struct {
+	n int
+	p float64
+}
+
+// DistinctApprox operation. Drop the elements whose key was probably seen before, tested with a Bloom filter
+// sized for n keys with the false positive rate p. Unlike Distinct it doesn't keep the elements in memory,
+// but an element may be dropped by mistake. keyFunc may be nil to compare the elements themselves.
+// keyFunc: func(o T) K
+func (s *Stream) DistinctApprox(keyFunc interface{}, n int, p float64) *Stream {
+	var funcValue reflect.Value
+	if keyFunc != nil {
+		funcValue = reflect.ValueOf(keyFunc)
+	}
+	s.ops = append(s.ops, op{typ: "distinctApprox", fun: funcValue, arg: bloomArg{n: n, p: p}})
+	return s
+}
+
+func distinctApproxStage(next iterator, op op) iterator {
+	arg := op.arg.(bloomArg)
+	filter := NewBloomFilter(arg.n, arg.p)
+	return func() (interface{}, bool) {
+		for {
+			it, ok := next()
+			if !ok {
+				return nil, false
+			}
+			key := it
+			if op.fun.IsValid() {
+				key = call(op.fun, it)[0].Interface()
+			}
+			if !filter.Add(key) {
+				return it, true
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package stream
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCountDistinctApprox(t *testing.T) {
+	stream, _ := GenN(100000, func(i int) int {
+		return i % 20000
+	})
+	n := stream.CountDistinctApprox(nil, 14)
+	if math.Abs(float64(n)-20000)/20000 > 0.03 {
+		t.Errorf("expected about 20000, got %d", n)
+	}
+
+	students := createStudents()
+	stream, _ = New(students)
+	names := stream.CountDistinctApprox(func(s student) string {
+		return s.name
+	}, 10)
+	stream.Reset()
+	exact := stream.Map(func(s student) string {
+		return s.name
+	}).Distinct(func(a, b string) bool {
+		return a == b
+	}).Count()
+	if int(names) != exact {
+		t.Errorf("expected %d names, got %d", exact, names)
+	}
+	fmt.Printf("%s: %d %d\n", t.Name(), n, names)
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	a, b := NewHyperLogLog(12), NewHyperLogLog(12)
+	for i := 0; i < 10000; i++ {
+		a.Add(i)
+		b.Add(i + 5000)
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if n := a.Count(); math.Abs(float64(n)-15000)/15000 > 0.05 {
+		t.Errorf("expected about 15000, got %d", n)
+	}
+	if err := a.Merge(NewHyperLogLog(10)); err == nil {
+		t.Error("expected error merging different precisions")
+	}
+}
+
+func TestTopFrequent(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	stream, _ := GenN(10000, func(i int) string {
+		switch {
+		case i%2 == 0:
+			return "a"
+		case i%5 == 0:
+			return "b"
+		}
+		return fmt.Sprint(rnd.Intn(1000))
+	})
+	top := stream.TopFrequent(2, nil)
+	if len(top) != 2 || top[0].Key != "a" || top[1].Key != "b" || top[0].Count-top[0].Error > 5000 || top[0].Count < 5000 {
+		t.Errorf("unexpected top %v", top)
+	}
+	fmt.Println(t.Name()+":", top)
+
+	stream, _ = Of([]int{1, 2}, []int{3}, []int{1, 2}, nil)
+	top = stream.TopFrequent(2, nil)
+	if fmt.Sprint(top) != "[{[1 2] 2 0} {<nil> 1 0}]" {
+		t.Errorf("unexpected top of slices %v", top)
+	}
+}
+
+func TestSpaceSavingMerge(t *testing.T) {
+	a, b := NewSpaceSaving(3), NewSpaceSaving(3)
+	for _, k := range []string{"x", "x", "x", "y", "y", "z"} {
+		a.Add(k)
+	}
+	for _, k := range []string{"x", "y", "y", "y", "w"} {
+		b.Add(k)
+	}
+	a.Merge(b)
+	top := a.Top(2)
+	if fmt.Sprint(top) != "[{y 5 0} {x 4 0}]" {
+		t.Errorf("unexpected top %v", top)
+	}
+}
+
+func TestSpaceSavingSlices(t *testing.T) {
+	a, b := NewSpaceSaving(2), NewSpaceSaving(2)
+	a.Add([]string{"x"})
+	a.Add([]string{"x"})
+	a.Add(map[string]int{"y": 1})
+	b.Add([]string{"x"})
+	b.Add([]string{"z"})
+	a.Merge(b)
+	if top := a.Top(1); fmt.Sprint(top) != "[{[x] 3 0}]" {
+		t.Errorf("unexpected top %v", top)
+	}
+}
+
+func TestDistinctApprox(t *testing.T) {
+	stream, _ := GenN(1000, func(i int) int {
+		return i % 100
+	})
+	var result []int
+	stream.DistinctApprox(nil, 100, 0.001).ToSlice(&result)
+	if len(result) < 99 || len(result) > 100 || result[0] != 0 {
+		t.Errorf("unexpected distinct elements %d", len(result))
+	}
+
+	students := createStudents()
+	stream, _ = New(students)
+	stream.DistinctApprox(func(s student) string {
+		return s.name
+	}, 10, 0.01)
+	first, second := stream.Count(), stream.Count()
+	if first != second {
+		t.Errorf("expected the same count on each run, got %d and %d", first, second)
+	}
+	fmt.Println(t.Name()+":", first)
+}
+
+func TestBloomFilterMerge(t *testing.T) {
+	a, b := NewBloomFilter(100, 0.01), NewBloomFilter(100, 0.01)
+	a.Add("x")
+	b.Add("y")
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Contains("x") || !a.Contains("y") {
+		t.Error("merged filter lost a key")
+	}
+	if err := a.Merge(NewBloomFilter(1000, 0.01)); err == nil {
+		t.Error("expected error merging different sizes")
+	}
+}
@@ -0,0 +1,107 @@
+package stream
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var rowsType = reflect.TypeOf((*sql.Rows)(nil))
+
+// FromRows create a stream from the rows of a query. scan is either a function func(rows *sql.Rows) (T, error)
+// that scans the current row, or a struct (or a pointer to a struct) whose exported fields are filled from the
+// columns with the same name as the `db:"name"` tag or the field name, a field tagged `db:"-"` is ignored.
+// Rows are scanned lazily and closed when the stream ends or stops early, the error of the rows is reported by Err.
+func FromRows(rows *sql.Rows, scan interface{}) (*Stream, error) {
+	scanFunc := reflect.ValueOf(scan)
+	var elemType reflect.Type
+	var scanRow func() (interface{}, error)
+	switch {
+	case scanFunc.Kind() == reflect.Func:
+		fnType := scanFunc.Type()
+		if fnType.NumOut() != 2 || validateFunc(scanFunc, []reflect.Type{rowsType}, []reflect.Type{fnType.Out(0), errorType}) != nil {
+			return nil, errors.New("the scan function must be like func(rows *sql.Rows) (T, error)")
+		}
+		elemType = fnType.Out(0)
+		scanRow = func() (interface{}, error) {
+			out := scanFunc.Call([]reflect.Value{reflect.ValueOf(rows)})
+			if err, _ := out[1].Interface().(error); err != nil {
+				return nil, err
+			}
+			return out[0].Interface(), nil
+		}
+	case scanFunc.IsValid() && derefType(scanFunc.Type()).Kind() == reflect.Struct:
+		elemType = scanFunc.Type()
+		columns, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		fields := rowFields(derefType(elemType), columns)
+		scanRow = func() (interface{}, error) {
+			value := reflect.New(derefType(elemType))
+			dest := make([]interface{}, len(fields))
+			for i, index := range fields {
+				if index < 0 {
+					dest[i] = new(interface{})
+				} else {
+					dest[i] = value.Elem().Field(index).Addr().Interface()
+				}
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return nil, err
+			}
+			if elemType.Kind() == reflect.Ptr {
+				return value.Interface(), nil
+			}
+			return value.Elem().Interface(), nil
+		}
+	default:
+		return nil, errors.New("the scan parameter must be a Func or a Struct")
+	}
+
+	s := &Stream{ops: make([]op, 0), res: elemType}
+	s.src = &source{
+		next: func() (interface{}, bool, error) {
+			if !rows.Next() {
+				return nil, false, rows.Err()
+			}
+			it, err := scanRow()
+			if err != nil {
+				return nil, false, fmt.Errorf("sql: %w", err)
+			}
+			return it, true, nil
+		},
+		close: rows.Close,
+	}
+	return s, nil
+}
+
+// rowFields returns the index of the field each column is scanned into, or -1 if there is none.
+func rowFields(t reflect.Type, columns []string) []int {
+	fields := make([]int, len(columns))
+	for i, column := range columns {
+		fields[i] = -1
+		for j := 0; j < t.NumField(); j++ {
+			f := t.Field(j)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := f.Name
+			if tag, ok := f.Tag.Lookup("db"); ok {
+				if tag == "-" {
+					continue
+				}
+				if tag != "" {
+					name = tag
+				}
+			}
+			if strings.EqualFold(name, column) {
+				fields[i] = j
+				break
+			}
+		}
+	}
+	return fields
+}
@@ -0,0 +1,160 @@
+package stream
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// memDriver is an in-memory database/sql driver, every query returns the rows of the table with the query name.
+type memDriver struct {
+	tables map[string]*memTable
+}
+
+type memTable struct {
+	columns []string
+	rows    [][]driver.Value
+	err     error
+	closed  bool
+}
+
+type memConn struct{ d *memDriver }
+
+type memStmt struct {
+	t *memTable
+}
+
+type memRows struct {
+	t *memTable
+	i int
+}
+
+func (d *memDriver) Open(name string) (driver.Conn, error) { return &memConn{d: d}, nil }
+
+func (c *memConn) Prepare(query string) (driver.Stmt, error) {
+	t, ok := c.d.tables[query]
+	if !ok {
+		return nil, fmt.Errorf("no table %s", query)
+	}
+	return &memStmt{t: t}, nil
+}
+func (c *memConn) Close() error              { return nil }
+func (c *memConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (s *memStmt) Close() error  { return nil }
+func (s *memStmt) NumInput() int { return 0 }
+func (s *memStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s *memStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.t.closed = false
+	return &memRows{t: s.t}, nil
+}
+
+func (r *memRows) Columns() []string { return r.t.columns }
+func (r *memRows) Close() error {
+	r.t.closed = true
+	return nil
+}
+func (r *memRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.t.rows) {
+		if r.t.err != nil {
+			return r.t.err
+		}
+		return io.EOF
+	}
+	copy(dest, r.t.rows[r.i])
+	r.i++
+	return nil
+}
+
+var memDB = &memDriver{tables: map[string]*memTable{
+	"users": {
+		columns: []string{"id", "user_name", "age", "created"},
+		rows: [][]driver.Value{
+			{int64(1), "Tom", int64(21), "2020-01-01"},
+			{int64(2), "Kate", nil, "2020-01-02"},
+			{int64(3), "Lucy", int64(23), "2020-01-03"},
+		},
+	},
+	"broken": {
+		columns: []string{"id"},
+		rows:    [][]driver.Value{{int64(1)}},
+		err:     errors.New("connection lost"),
+	},
+}}
+
+func init() {
+	sql.Register("memdb", memDB)
+}
+
+type user struct {
+	ID   int
+	Name string `db:"user_name"`
+	Age  *int
+	Note string `db:"-"`
+}
+
+func queryRows(t *testing.T, query string) *sql.Rows {
+	db, err := sql.Open("memdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestFromRows(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	stream, err := FromRows(queryRows(t, "users"), user{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []user
+	stream.ToSlice(&users)
+	if len(users) != 3 || users[1].Name != "Kate" || users[1].Age != nil || *users[2].Age != 23 {
+		t.Errorf("unexpected users: %v", users)
+	}
+	if !memDB.tables["users"].closed {
+		t.Error("rows not closed")
+	}
+	fmt.Printf("\t%v\n", users)
+}
+
+func TestFromRowsFunc(t *testing.T) {
+	stream, err := FromRows(queryRows(t, "users"), func(rows *sql.Rows) (string, error) {
+		var id int
+		var name, created string
+		var age *int
+		err := rows.Scan(&id, &name, &age, &created)
+		return name, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	stream.Limit(1).ToSlice(&names)
+	if len(names) != 1 || names[0] != "Tom" {
+		t.Errorf("unexpected names: %v", names)
+	}
+	if !memDB.tables["users"].closed {
+		t.Error("rows not closed after limit")
+	}
+
+	_, err = FromRows(queryRows(t, "users"), func(rows *sql.Rows) string { return "" })
+	fmt.Println(t.Name()+":", err)
+}
+
+func TestFromRowsErr(t *testing.T) {
+	stream, _ := FromRows(queryRows(t, "broken"), &user{})
+	if n := stream.Count(); n != 1 || stream.Err() == nil {
+		t.Errorf("count %d, err %v", n, stream.Err())
+	}
+	fmt.Println(t.Name()+":", stream.Err())
+}
@@ -5,20 +5,82 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 var StrictMode bool
 
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
 type Stream struct {
-	ops  []op
-	data []interface{}
-	res  reflect.Type
+	ops   []op
+	data  []interface{}
+	res   reflect.Type
+	src   *source
+	mu    sync.Mutex // guards err and errs, which stages running in the background may set
+	err   error
+	errs  []error
+	bad   error // the error of an operation that couldn't be added, dropped with the operations by Reset
+	once  bool
+	used  bool
+	clock Clock
+
+	observer Observer
+	rewrites []string
+}
+
+// ErrorPolicy decides what a source does with an element it fails to read.
+type ErrorPolicy int
+
+const (
+	// ErrorFail stops the stream, the error is reported by Err.
+	ErrorFail ErrorPolicy = iota
+	// ErrorSkip drops the element and ignores the error.
+	ErrorSkip
+	// ErrorCollect drops the element and keeps the error, see Errors.
+	ErrorCollect
+)
+
+// source is a lazily read input of a stream, such as a reader.
+// Unlike a slice it can be read only once.
+type source struct {
+	next  func() (interface{}, bool, error)
+	close func() error
+}
+
+func (src *source) iterator(s *Stream) (iterator, func()) {
+	// closed is atomic, as a stage may leave a goroutine blocked in next when it stops.
+	var closed atomic.Bool
+	done := func() {
+		if closed.CompareAndSwap(false, true) && src.close != nil {
+			if err := src.close(); err != nil {
+				s.fail(err)
+			}
+		}
+	}
+	return func() (interface{}, bool) {
+		if closed.Load() || s.Err() != nil {
+			return nil, false
+		}
+		it, ok, err := src.next()
+		if err != nil {
+			s.fail(err)
+			ok = false
+		}
+		if !ok {
+			done()
+		}
+		return it, ok
+	}, done
 }
 
 type op struct {
-	typ string
-	fun reflect.Value
-	idx bool
+	typ   string
+	fun   reflect.Value
+	idx   bool
+	arg   interface{}
+	onErr errorHandler
 }
 
 type FuncSorter struct {
@@ -123,8 +185,117 @@ func GenN(num int, genFunc interface{}) (*Stream, error) {
 	return New(data)
 }
 
+// Concat create a stream of the elements of streams, one stream after the other. The streams are read
+// lazily, so the stream can be consumed only once. The error of one of the streams stops the stream.
+func Concat(streams ...*Stream) (*Stream, error) {
+	var res reflect.Type
+	for i, st := range streams {
+		if st == nil {
+			return nil, errors.New("the streams parameter must not contain nil")
+		}
+		if t := st.elemType(); i == 0 {
+			res = t
+		} else if t != res {
+			res = anyType
+		}
+	}
+	i := 0
+	var next iterator
+	var done func()
+	s := &Stream{ops: make([]op, 0), res: res}
+	s.src = &source{next: func() (interface{}, bool, error) {
+		for i < len(streams) {
+			if next == nil {
+				next, done = streams[i].iterator()
+			}
+			if it, ok := next(); ok {
+				return it, true, nil
+			}
+			done()
+			next = nil
+			if err := streams[i].Err(); err != nil {
+				return nil, false, err
+			}
+			i++
+		}
+		return nil, false, nil
+	}, close: func() error {
+		if next != nil {
+			done()
+			next = nil
+		}
+		return nil
+	}}
+	return s, nil
+}
+
+// Err returns the error of an operation that couldn't be added, or else the error that stopped the last run of
+// the stream, if any.
+func (s *Stream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bad != nil {
+		return s.bad
+	}
+	return s.err
+}
+
+// Errors returns the errors collected by a source with the ErrorCollect policy.
+func (s *Stream) Errors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.errs
+}
+
+// fail records the first error that stopped the stream.
+func (s *Stream) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// failOp records the error of an operation that couldn't be added, the stream doesn't run until Reset.
+func (s *Stream) failOp(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bad == nil {
+		s.bad = err
+	}
+}
+
+// takeOp returns the error of an operation that couldn't be added and drops it.
+func (s *Stream) takeOp() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.bad
+	s.bad = nil
+	return err
+}
+
+// handle applies the policy to an element error, it returns the error if the stream must stop.
+func (s *Stream) handle(policy ErrorPolicy, err error) error {
+	switch policy {
+	case ErrorSkip:
+		return nil
+	case ErrorCollect:
+		s.mu.Lock()
+		s.errs = append(s.errs, err)
+		s.mu.Unlock()
+		return nil
+	}
+	return err
+}
+
+// Reset drops the operations and the errors, so that the stream can be reused. A single-use stream that was
+// already run still fails with ErrStreamConsumed.
 func (s *Stream) Reset() *Stream {
 	s.ops = make([]op, 0)
+	s.rewrites = nil
+	s.mu.Lock()
+	s.bad, s.err, s.errs = nil, nil, nil
+	s.mu.Unlock()
 	return s
 }
 
@@ -238,60 +409,218 @@ func (s *Stream) Skip(num int) *Stream {
 	return s
 }
 
+// iterator yields the elements of a pipeline stage one by one, more is false once the stage is exhausted.
+type iterator func() (it interface{}, more bool)
+
 // collect operation.
 func (s *Stream) collect() []interface{} {
-	result := s.data
-	for _, op := range s.ops {
-		if len(result) == 0 {
-			break
+	next, done := s.iterator()
+	defer done()
+	return drain(next)
+}
+
+// iterator chains the stages of the pipeline on top of the source. done must be called
+// once the caller stops pulling elements, it releases the source.
+func (s *Stream) iterator() (next iterator, done func()) {
+	s.mu.Lock()
+	if s.bad != nil {
+		s.mu.Unlock()
+		return sliceIterator(nil), func() {}
+	}
+	// Each run starts afresh, the errors of a previous run are dropped.
+	s.err, s.errs = nil, nil
+	s.mu.Unlock()
+	ops, first := s.ops, 0
+	if i := lastCache(ops); i >= 0 {
+		next, done = sliceIterator(ops[i].arg.(*cache).data), func() {}
+		ops, first = ops[i+1:], i+1
+	} else {
+		next, done = s.source()
+	}
+	var t *tracer
+	if s.observer != nil {
+		t = newTracer(s, first)
+		next = t.traced(0, next)
+	}
+	for i, op := range ops {
+		if t != nil {
+			t.build()
 		}
 		switch op.typ {
 		case "filter":
-			result = doFilter(result, op)
+			next = filterStage(next, op)
 		case "peek":
-			each(result, op.fun, emptyeachfunc, op.idx)
+			next = peekStage(next, op)
 		case "map":
-			result = doMap(result, op)
+			next = mapStage(next, op)
 		case "flatMap":
-			result = doFlatMap(result, op)
-		case "aggMap":
-
-		case "sort":
-			sort.Sort(&FuncSorter{data: result, fun: op.fun})
-		case "distinct":
-			result = doDistinct(result, op)
+			next = flatMapStage(next, op)
 		case "limit":
-			result = doLimit(op, result)
+			next = limitStage(next, op)
 		case "skip":
-			result = doSkip(op, result)
-		case "call":
-			call(op.fun)
-		case "check":
-			out := call(op.fun, result)
-			if !out[0].Bool() {
+			next = skipStage(next, op)
+		case "distinctApprox":
+			next = distinctApproxStage(next, op)
+		case "sample":
+			next = sampleStage(next, op)
+		case "sampleFraction":
+			next = sampleFractionStage(next, op)
+		case "shuffle":
+			next = shuffleStage(next, op)
+		case "stratifiedSample":
+			next = stratifiedSampleStage(next, op)
+		case "cache":
+			next = cacheStage(next, op, s)
+		case "rateLimit":
+			next = rateLimitStage(next, op, s.getClock())
+		case "throttle":
+			next = throttleStage(next, op, s.getClock())
+		case "debounce":
+			next = debounceStage(next, op, s.getClock())
+		case "delay":
+			next = delayStage(next, op, s.getClock())
+		case "mapRetry":
+			next = mapRetryStage(next, op, s)
+		case "scan":
+			next = scanStage(next, op)
+		case "batch":
+			if op.arg.(batchArg).wait <= 0 {
+				next = batchStage(next, op)
 				break
 			}
+			var stop func()
+			var f *feeder
+			next, stop, f = timedBatchStage(next, op, s.getClock())
+			done = stopThen(stop, done)
+			if t != nil {
+				t.feeders = append(t.feeders, f)
+			}
+		case "mapAsync":
+			var stop func()
+			var f *feeder
+			next, stop, f = mapAsyncStage(next, op, s)
+			done = stopThen(stop, done)
+			if t != nil {
+				t.feeders = append(t.feeders, f)
+			}
+		default:
+			next = barrierStage(next, op)
+		}
+		if t != nil {
+			next = t.traced(i+1, next)
+		}
+	}
+	if t != nil {
+		done = t.finish(done)
+	}
+	return next, done
+}
+
+// elemType returns the type of the elements after the operations, as declared by the source and the functions.
+func (s *Stream) elemType() reflect.Type {
+	t := s.res
+	for _, op := range s.ops {
+		t = op.outType(t)
+	}
+	return t
+}
+
+// outType returns the type of the elements after the operation, given the type before it.
+func (op op) outType(t reflect.Type) reflect.Type {
+	switch op.typ {
+	case "map", "mapAsync", "mapRetry", "scan":
+		return op.fun.Type().Out(0)
+	case "flatMap":
+		return op.fun.Type().Out(0).Elem()
+	case "batch":
+		return reflect.SliceOf(op.arg.(batchArg).typ)
+	}
+	return t
+}
+
+// source returns the iterator over the stream's input, a single-use stream fails if it was already read.
+func (s *Stream) source() (iterator, func()) {
+	if s.used && (s.once || s.src != nil) {
+		s.fail(ErrStreamConsumed)
+		return sliceIterator(nil), func() {}
+	}
+	s.used = true
+	if s.src == nil {
+		return sliceIterator(s.data), func() {}
+	}
+	return s.src.iterator(s)
+}
+
+// stopThen returns a done function that stops a stage running in the background, then releases what is upstream.
+func stopThen(stop func(), done func()) func() {
+	return func() {
+		stop()
+		done()
+	}
+}
+
+func sliceIterator(data []interface{}) iterator {
+	i := 0
+	return func() (interface{}, bool) {
+		if i >= len(data) {
+			return nil, false
 		}
+		i++
+		return data[i-1], true
+	}
+}
+
+func drain(next iterator) []interface{} {
+	result := make([]interface{}, 0)
+	for it, ok := next(); ok; it, ok = next() {
+		result = append(result, it)
 	}
 	return result
 }
 
-func doSkip(op op, result []interface{}) []interface{} {
+// barrierStage runs the operations that need all the elements of the previous stage.
+func barrierStage(next iterator, op op) iterator {
+	result := drain(next)
+	if len(result) == 0 {
+		return sliceIterator(result)
+	}
+	switch op.typ {
+	case "sort":
+		sort.Sort(&FuncSorter{data: result, fun: op.fun})
+	case "distinct":
+		result = doDistinct(result, op)
+	case "call":
+		call(op.fun)
+	case "check":
+		out := call(op.fun, result)
+		if !out[0].Bool() {
+			break
+		}
+	}
+	return sliceIterator(result)
+}
+
+func skipStage(next iterator, op op) iterator {
 	skip := int(call(op.fun)[0].Int())
-	if skip > len(result) {
-		skip = len(result)
+	return func() (interface{}, bool) {
+		for ; skip > 0; skip-- {
+			if _, ok := next(); !ok {
+				return nil, false
+			}
+		}
+		return next()
 	}
-	temp := result
-	return temp[skip:]
 }
 
-func doLimit(op op, result []interface{}) []interface{} {
+func limitStage(next iterator, op op) iterator {
 	limit := int(call(op.fun)[0].Int())
-	if limit > len(result) {
-		limit = len(result)
+	return func() (interface{}, bool) {
+		if limit <= 0 {
+			return nil, false
+		}
+		limit--
+		return next()
 	}
-	temp := result
-	return temp[:limit]
 }
 
 func doDistinct(result []interface{}, op op) []interface{} {
@@ -312,37 +641,70 @@ func doDistinct(result []interface{}, op op) []interface{} {
 	return temp
 }
 
-func doFlatMap(result []interface{}, op op) []interface{} {
-	temp := make([]interface{}, 0)
-	tempVlaue := reflect.ValueOf(&temp).Elem()
-	each(result, op.fun, func(i int, it interface{}, out []reflect.Value) bool {
-		for i := 0; i < out[0].Len(); i++ {
-			tempVlaue.Set(reflect.Append(tempVlaue, out[0].Index(i)))
+func flatMapStage(next iterator, op op) iterator {
+	i := -1
+	var out reflect.Value
+	j := 0
+	return func() (interface{}, bool) {
+		for !out.IsValid() || j >= out.Len() {
+			it, ok := next()
+			if !ok {
+				return nil, false
+			}
+			i++
+			out, j = apply(op, it, i)[0], 0
 		}
-		return true
-	}, op.idx)
-	return temp
+		j++
+		return out.Index(j - 1).Interface(), true
+	}
 }
 
-func doMap(result []interface{}, op op) []interface{} {
-	temp := make([]interface{}, 0)
-	tempValue := reflect.ValueOf(&temp).Elem()
-	each(result, op.fun, func(i int, it interface{}, out []reflect.Value) bool {
-		tempValue.Set(reflect.Append(tempValue, out[0]))
-		return true
-	}, op.idx)
-	return temp
+func mapStage(next iterator, op op) iterator {
+	i := -1
+	return func() (interface{}, bool) {
+		it, ok := next()
+		if !ok {
+			return nil, false
+		}
+		i++
+		return apply(op, it, i)[0].Interface(), true
+	}
 }
 
-func doFilter(result []interface{}, op op) []interface{} {
-	temp := make([]interface{}, 0)
-	each(result, op.fun, func(i int, it interface{}, out []reflect.Value) bool {
-		if out[0].Bool() {
-			temp = append(temp, it)
+func peekStage(next iterator, op op) iterator {
+	i := -1
+	return func() (interface{}, bool) {
+		it, ok := next()
+		if ok {
+			i++
+			apply(op, it, i)
 		}
-		return true
-	}, op.idx)
-	return temp
+		return it, ok
+	}
+}
+
+func filterStage(next iterator, op op) iterator {
+	i := -1
+	return func() (interface{}, bool) {
+		for {
+			it, ok := next()
+			if !ok {
+				return nil, false
+			}
+			i++
+			if apply(op, it, i)[0].Bool() {
+				return it, true
+			}
+		}
+	}
+}
+
+// apply calls the function of op with the element, and its index if op is an index operation.
+func apply(op op, it interface{}, i int) []reflect.Value {
+	if op.idx {
+		return call(op.fun, it, i)
+	}
+	return call(op.fun, it)
 }
 
 // Exec operation.
@@ -359,9 +721,13 @@ func (s *Stream) ToSlice(targetSlice interface{}) error {
 	}
 	sliceValue := reflect.Indirect(targetValue)
 	for _, it := range data {
-		sliceValue.Set(reflect.Append(sliceValue, reflect.ValueOf(it)))
+		v := reflect.ValueOf(it)
+		if it == nil {
+			v = reflect.Zero(sliceValue.Type().Elem())
+		}
+		sliceValue.Set(reflect.Append(sliceValue, v))
 	}
-	return nil
+	return s.Err()
 }
 
 // ForEach executes a provided function once for each array element,and terminate the stream.
@@ -491,65 +857,82 @@ func (s *Stream) GroupIndex(groupFunc interface{}) map[interface{}][]interface{}
 	return s.group(groupFunc, true)
 }
 
-// Max operation.lessFunc: func(o1,o2 T) bool
-func (s *Stream) Max(lessFunc interface{}) interface{} {
+// Max operation, the result is absent if there is no element. lessFunc: func(o1,o2 T) bool
+func (s *Stream) Max(lessFunc interface{}) Optional {
 	funcValue := reflect.ValueOf(lessFunc)
 	data := s.collect()
-	var max interface{}
-	if len(data) > 0 {
-		max = data[0]
-		for i := 1; i < len(data); i++ {
-			out := call(funcValue, max, data[i])
-			if out[0].Bool() {
-				max = data[i]
-			}
+	if len(data) == 0 {
+		return Optional{}
+	}
+	max := data[0]
+	for i := 1; i < len(data); i++ {
+		out := call(funcValue, max, data[i])
+		if out[0].Bool() {
+			max = data[i]
 		}
 	}
-	return max
+	return OptionalOf(max)
 }
 
-// Min operation.lessFunc: func(o1,o2 T) bool
-func (s *Stream) Min(lessFunc interface{}) interface{} {
+// Min operation, the result is absent if there is no element. lessFunc: func(o1,o2 T) bool
+func (s *Stream) Min(lessFunc interface{}) Optional {
 	funcValue := reflect.ValueOf(lessFunc)
 	data := s.collect()
-	var min interface{}
-	if len(data) > 0 {
-		min = data[0]
-		for i := 1; i < len(data); i++ {
-			out := call(funcValue, data[i], min)
-			if out[0].Bool() {
-				min = data[i]
-			}
+	if len(data) == 0 {
+		return Optional{}
+	}
+	min := data[0]
+	for i := 1; i < len(data); i++ {
+		out := call(funcValue, data[i], min)
+		if out[0].Bool() {
+			min = data[i]
 		}
 	}
-	return min
+	return OptionalOf(min)
 }
 
-// First operation. matchFunc: func(o T) bool
-func (s *Stream) First(matchFunc interface{}) interface{} {
+// First operation, the result is absent if no element matches. matchFunc: func(o T) bool
+func (s *Stream) First(matchFunc interface{}) Optional {
 	data := s.collect()
 	funcValue := reflect.ValueOf(matchFunc)
 	for _, it := range data {
 		out := call(funcValue, it)
 		if out[0].Bool() {
-			return it
+			return OptionalOf(it)
 		}
 	}
-	return nil
+	return Optional{}
 }
 
-// Last operation. matchFunc: func(o T) bool
-func (s *Stream) Last(matchFunc interface{}) interface{} {
+// Last operation, the result is absent if no element matches. matchFunc: func(o T) bool
+func (s *Stream) Last(matchFunc interface{}) Optional {
 	data := s.collect()
 	funcValue := reflect.ValueOf(matchFunc)
 	for i := len(data) - 1; i >= 0; i-- {
 		it := data[i]
 		out := call(funcValue, it)
 		if out[0].Bool() {
-			return it
+			return OptionalOf(it)
 		}
 	}
-	return nil
+	return Optional{}
+}
+
+// FindFirst operation. Return the first element, absent if there is none.
+// The elements after it aren't pulled.
+func (s *Stream) FindFirst() Optional {
+	next, done := s.iterator()
+	defer done()
+	if it, ok := next(); ok {
+		return OptionalOf(it)
+	}
+	return Optional{}
+}
+
+// FindAny operation. Return any element, absent if there is none.
+// Which one isn't specified, it is the cheapest to get.
+func (s *Stream) FindAny() Optional {
+	return s.FindFirst()
 }
 
 func (s *Stream) reduce(initValue interface{}, reduceFunc interface{}, idx bool) interface{} {
@@ -604,7 +987,7 @@ func each(data []interface{}, fun reflect.Value, act eachfunc, idx bool) {
 func call(fun reflect.Value, args ...interface{}) []reflect.Value {
 	in := make([]reflect.Value, len(args))
 	for i, a := range args {
-		in[i] = reflect.ValueOf(a).Convert(fun.Type().In(i))
+		in[i] = convertValue(a, fun.Type().In(i))
 	}
 	return fun.Call(in)
 }
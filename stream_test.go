@@ -5,6 +5,7 @@ import (
 	"math/rand"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -22,11 +23,12 @@ func (s *student) String() string {
 func createStudents() []student {
 	names := []string{"Tom", "Kate", "Lucy", "Jim", "Jack", "King", "Lee", "Mask"}
 	students := make([]student, 10)
-	rnd := func(start, end int) int { return rand.Intn(end-start) + start }
+	r := rand.New(rand.NewSource(1))
+	rnd := func(start, end int) int { return r.Intn(end-start) + start }
 	for i := 0; i < 10; i++ {
 		students[i] = student{
 			id:     i + 1,
-			name:   names[rand.Intn(len(names))],
+			name:   names[r.Intn(len(names))],
 			age:    rnd(15, 26),
 			scores: []int{rnd(60, 100), rnd(60, 100), rnd(60, 100)},
 		}
@@ -129,6 +131,67 @@ func TestGenerateN(t *testing.T) {
 	fmt.Println()
 }
 
+func TestConcat(t *testing.T) {
+	s1, _ := Ints(1, 2)
+	s2, _ := Ints()
+	s3, _ := Ints(3, 4, 5)
+	stream, _ := Concat(s1, s2, s3)
+	var result []int64
+	stream.Filter(func(i int64) bool {
+		return i != 4
+	}).ToSlice(&result)
+	fmt.Println(t.Name()+":", result)
+	if fmt.Sprint(result) != "[1 2 3 5]" {
+		t.Errorf("unexpected elements %v", result)
+	}
+
+	s1, _ = Ints(1, 2)
+	s3, _ = Ints(3, 4, 5)
+	stream, _ = Concat(s1.Map(func(i int64) int64 { return -i }), s3)
+	if n := stream.Limit(3).Count(); n != 3 {
+		t.Errorf("expected 3 elements, got %d", n)
+	}
+}
+
+func TestConcatErrors(t *testing.T) {
+	good, _ := Ints(1, 2)
+	bad, _ := FromJSONLines(strings.NewReader("3\nx\n5"), int64(0))
+	stream, _ := Concat(good, bad)
+	var result []int64
+	err := stream.ToSlice(&result)
+	if err == nil || fmt.Sprint(result) != "[1 2 3]" {
+		t.Errorf("expected the error of the second stream after [1 2 3], got %v, %v", result, err)
+	}
+	if err := stream.ToSlice(&result); err != ErrStreamConsumed {
+		t.Errorf("expected a concatenation to be single-use, got %v", err)
+	}
+
+	if _, err := Concat(good, nil); err == nil {
+		t.Errorf("expected an error for a nil stream")
+	}
+	ints, _ := Ints(1)
+	strs, _ := Strings("a")
+	stream, _ = Concat(ints, strs)
+	var mixed []interface{}
+	if err := stream.ToSlice(&mixed); err != nil || fmt.Sprint(mixed) != "[1 a]" {
+		t.Errorf("unexpected elements %v, %v", mixed, err)
+	}
+}
+
+func TestCallNil(t *testing.T) {
+	stream, _ := New([]*student{nil, {name: "Tom"}})
+	if n := stream.Filter(func(s *student) bool { return s == nil }).Count(); n != 1 {
+		t.Errorf("expected 1 nil student, got %d", n)
+	}
+
+	stream, _ = Of("a", nil, "c")
+	var result []string
+	stream.Map(func(s string) string { return s + "!" }).ToSlice(&result)
+	if fmt.Sprint(result) != "[a! ! c!]" {
+		t.Errorf("expected nil to be passed as the zero value, got %q", result)
+	}
+}
+
 func TestFilter(t *testing.T) {
 	fmt.Println(t.Name() + ": by age > 20")
 
@@ -305,7 +368,16 @@ func TestMaxMin(t *testing.T) {
 	r2 := stream.Min(func(s1, s2 student) bool {
 		return s1.scores[0]+s1.scores[1]+s1.scores[2] < s2.scores[0]+s2.scores[1]+s2.scores[2]
 	})
-	fmt.Printf("\tMax: %v, Min: %v \n", r1, r2)
+	fmt.Printf("\tMax: %v, Min: %v \n", r1.Get(), r2.Get())
+	var max student
+	if !r1.Into(&max) || max.name != "King" {
+		t.Errorf("unexpected max %v", r1)
+	}
+
+	stream, _ = New([]student{})
+	if r := stream.Max(func(s1, s2 student) bool { return s1.age < s2.age }); r.IsPresent() {
+		t.Errorf("expected no max of no student, got %v", r)
+	}
 }
 
 func TestPeek(t *testing.T) {
@@ -399,6 +471,30 @@ func TestLimitSkipNeg(t *testing.T) {
 	fmt.Println()
 }
 
+func TestLazy(t *testing.T) {
+	stream, _ := Ints(1, 2, 3, 4, 5)
+	peeked, mapped := 0, 0
+	var result []int64
+	stream.Peek(func(i int64) {
+		peeked++
+	}).Map(func(i int64) int64 {
+		mapped++
+		return i * 10
+	}).Limit(2).ToSlice(&result)
+	if fmt.Sprint(result) != "[10 20]" || peeked != 2 || mapped != 2 {
+		t.Errorf("expected 2 elements peeked and mapped, got %v, %d, %d", result, peeked, mapped)
+	}
+
+	stream, _ = Ints(3, 1, 2)
+	peeked = 0
+	stream.Sort(func(a, b int64) bool { return a < b }).Peek(func(i int64) {
+		peeked++
+	}).Limit(1).Count()
+	if peeked != 1 {
+		t.Errorf("expected a sort to read all elements but emit them one by one, got %d peeked", peeked)
+	}
+}
+
 func TestReduce(t *testing.T) {
 	fmt.Println(t.Name() + ": sum of scores[0]")
 	students := createStudents()
@@ -507,6 +603,14 @@ func TestFirstLast(t *testing.T) {
 		return s.age > 18
 	})
 	fmt.Println(last)
+
+	stream, _ = New(students)
+	none := stream.First(func(s student) bool {
+		return s.age > 100
+	})
+	if none.IsPresent() || none.Get() != nil {
+		t.Errorf("expected no student older than 100, got %v", none)
+	}
 }
 
 func TestValidateFunc(t *testing.T) {
@@ -527,3 +631,23 @@ func TestValidateFunc(t *testing.T) {
 	err4 := validateFunc(reflect.ValueOf(fn4), []reflect.Type{reflect.TypeOf(0)}, []reflect.Type{reflect.TypeOf("")})
 	fmt.Println(fmt.Sprintf("validate 'func(int) string {}' by in(int) out(string): %t", err4 == nil))
 }
+
+func TestResetErrors(t *testing.T) {
+	stream, _ := Ints(1, 2, 3)
+	if q := stream.Quantiles(2); q != nil || stream.Err() == nil {
+		t.Errorf("expected an invalid quantile to fail, got %v, %v", q, stream.Err())
+	}
+	if n := stream.Count(); n != 3 || stream.Err() != nil {
+		t.Errorf("expected a new run to drop the error, got %d elements, %v", n, stream.Err())
+	}
+	stream.Quantiles(2)
+	if stream.Reset().Err() != nil {
+		t.Errorf("expected Reset to drop the error, got %v", stream.Err())
+	}
+
+	stream, _ = FromJSONLines(strings.NewReader("1\n2"), 0)
+	stream.Count()
+	if n := stream.Reset().Count(); n != 0 || stream.Err() != ErrStreamConsumed {
+		t.Errorf("expected a consumed stream to stay consumed, got %d elements, %v", n, stream.Err())
+	}
+}
@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"fmt"
+	"sync"
+)
+
+// teeState is the upstream pass shared by the branches of Tee.
+type teeState struct {
+	mu     sync.Mutex
+	s      *Stream
+	next   iterator
+	done   func()
+	ended  bool
+	queues [][]interface{}
+}
+
+// Tee operation. Return n streams that each yield all the elements, the operations before Tee run only once
+// for all of them. The elements a branch hasn't read yet are buffered, so when branches are consumed one after
+// the other the buffer holds all the elements; use Broadcast to bound it. The branches can be consumed
+// concurrently and each can be consumed only once. A negative n is an error reported by Err, Tee returns nil.
+func (s *Stream) Tee(n int) []*Stream {
+	if n < 0 {
+		s.failOp(fmt.Errorf("number of branches must not be negative, got %d", n))
+		return nil
+	}
+	state := &teeState{s: s, queues: make([][]interface{}, n)}
+	branches := make([]*Stream, n)
+	for i := range branches {
+		i := i
+		branch := &Stream{ops: make([]op, 0), res: s.elemType()}
+		branch.src = &source{next: func() (interface{}, bool, error) {
+			return state.pull(i)
+		}}
+		branches[i] = branch
+	}
+	return branches
+}
+
+func (t *teeState) pull(i int) (interface{}, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queues[i]) > 0 {
+		it := t.queues[i][0]
+		t.queues[i] = t.queues[i][1:]
+		return it, true, nil
+	}
+	if t.ended {
+		return nil, false, t.s.Err()
+	}
+	if t.next == nil {
+		t.next, t.done = t.s.iterator()
+	}
+	it, ok := t.next()
+	if !ok {
+		t.ended = true
+		t.done()
+		return nil, false, t.s.Err()
+	}
+	for j := range t.queues {
+		if j != i {
+			t.queues[j] = append(t.queues[j], it)
+		}
+	}
+	return it, true, nil
+}
+
+// Broadcast operation. Run each branch on a stream of all the elements, concurrently and in one pass of the
+// operations before Broadcast, and return what each branch returns. Each branch buffers at most bufferSize
+// elements it hasn't read yet, the pass waits for the slowest branch. A branch that returns early stops
+// receiving elements. A negative bufferSize is an error, as is an error of the operations before Broadcast.
+// branch: func(s *Stream) interface{}
+func (s *Stream) Broadcast(bufferSize int, branches ...func(*Stream) interface{}) ([]interface{}, error) {
+	if bufferSize < 0 {
+		return nil, fmt.Errorf("buffer size must not be negative, got %d", bufferSize)
+	}
+	results := make([]interface{}, len(branches))
+	channels := make([]chan interface{}, len(branches))
+	finished := make([]chan struct{}, len(branches))
+	var wg sync.WaitGroup
+	for i, branch := range branches {
+		ch := make(chan interface{}, bufferSize)
+		channels[i], finished[i] = ch, make(chan struct{})
+		b := &Stream{ops: make([]op, 0), res: s.elemType()}
+		b.src = &source{next: func() (interface{}, bool, error) {
+			it, ok := <-ch
+			if !ok {
+				return nil, false, s.Err()
+			}
+			return it, true, nil
+		}}
+		wg.Add(1)
+		go func(i int, branch func(*Stream) interface{}) {
+			defer wg.Done()
+			defer close(finished[i])
+			results[i] = branch(b)
+		}(i, branch)
+	}
+
+	next, done := s.iterator()
+	for it, ok := next(); ok; it, ok = next() {
+		for i, ch := range channels {
+			select {
+			case ch <- it:
+			case <-finished[i]:
+			}
+		}
+	}
+	done()
+	for _, ch := range channels {
+		close(ch)
+	}
+	wg.Wait()
+	return results, s.Err()
+}
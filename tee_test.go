@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestTee(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	students := createStudents()
+	stream, _ := New(students)
+
+	calls := 0
+	branches := stream.Filter(func(s student) bool {
+		calls++
+		return s.age > 18
+	}).Tee(2)
+	count := branches[0].Count()
+	var names []string
+	branches[1].Map(func(s student) string {
+		return s.name
+	}).ToSlice(&names)
+	if calls != len(students) {
+		t.Errorf("expected filter to run %d times, got %d", len(students), calls)
+	}
+	if len(names) != count {
+		t.Errorf("expected %d names, got %v", count, names)
+	}
+	fmt.Printf("\t%d %v\n", count, names)
+}
+
+func TestTeeNegative(t *testing.T) {
+	stream, _ := Ints(1, 2)
+	if branches := stream.Tee(-1); branches != nil || stream.Err() == nil {
+		t.Errorf("expected an error for a negative number of branches, got %v, %v", branches, stream.Err())
+	}
+	if n := stream.Reset().Count(); n != 2 {
+		t.Errorf("expected Reset to drop the error, got %d elements, %v", n, stream.Err())
+	}
+}
+
+func TestTeeConcurrent(t *testing.T) {
+	stream, _ := GenN(1000, func(i int) int { return i })
+	branches := stream.Tee(3)
+	sums := make([]interface{}, 3)
+	var wg sync.WaitGroup
+	for i, b := range branches {
+		wg.Add(1)
+		go func(i int, b *Stream) {
+			defer wg.Done()
+			sums[i] = b.Sum()
+		}(i, b)
+	}
+	wg.Wait()
+	for _, sum := range sums {
+		if sum != 499500 {
+			t.Errorf("unexpected sums %v", sums)
+		}
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	stream, _ := GenN(1000, func(i int) int { return i })
+	calls := 0
+	results, err := stream.Peek(func(i int) {
+		calls++
+	}).Broadcast(4, func(b *Stream) interface{} {
+		return b.Count()
+	}, func(b *Stream) interface{} {
+		return b.Filter(func(i int) bool { return i%2 == 0 }).Sum()
+	}, func(b *Stream) interface{} {
+		var first []int
+		b.Limit(3).ToSlice(&first)
+		return first
+	})
+	if err != nil || calls != 1000 {
+		t.Errorf("expected peek to run 1000 times, got %d, %v", calls, err)
+	}
+	if fmt.Sprint(results) != "[1000 249500 [0 1 2]]" {
+		t.Errorf("unexpected results %v", results)
+	}
+	fmt.Println(t.Name()+":", results)
+
+	stream.Reset()
+	if _, err := stream.Broadcast(-1, func(b *Stream) interface{} { return b.Count() }); err == nil {
+		t.Errorf("expected a negative buffer size to be an error")
+	}
+}
@@ -0,0 +1,150 @@
+package stream
+
+import "time"
+
+// Clock tells the time and waits for the timing operations, tests can replace it to run them instantly.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock sets the clock of the timing operations, by default the real time.
+func (s *Stream) WithClock(clock Clock) *Stream {
+	s.clock = clock
+	return s
+}
+
+func (s *Stream) getClock() Clock {
+	if s.clock == nil {
+		return realClock{}
+	}
+	return s.clock
+}
+
+// rateArg is the argument of the timing operations.
+type rateArg struct {
+	n   int
+	per time.Duration
+}
+
+// RateLimit operation. Let at most n elements pass per period, waiting when the limit is reached.
+// Up to n elements pass at once, as from a token bucket of n tokens refilled at n per period.
+func (s *Stream) RateLimit(n int, per time.Duration) *Stream {
+	if n < 1 {
+		n = 1
+	}
+	s.ops = append(s.ops, op{typ: "rateLimit", arg: rateArg{n: n, per: per}})
+	return s
+}
+
+// Throttle operation. Let an element pass, then drop the elements that arrive within d after it.
+func (s *Stream) Throttle(d time.Duration) *Stream {
+	s.ops = append(s.ops, op{typ: "throttle", arg: rateArg{per: d}})
+	return s
+}
+
+// Debounce operation. Let an element pass only if no other element arrives within d after it,
+// the last element always passes.
+func (s *Stream) Debounce(d time.Duration) *Stream {
+	s.ops = append(s.ops, op{typ: "debounce", arg: rateArg{per: d}})
+	return s
+}
+
+// Delay operation. Wait d before letting each element pass.
+func (s *Stream) Delay(d time.Duration) *Stream {
+	s.ops = append(s.ops, op{typ: "delay", arg: rateArg{per: d}})
+	return s
+}
+
+func rateLimitStage(next iterator, op op, clock Clock) iterator {
+	arg := op.arg.(rateArg)
+	interval := arg.per / time.Duration(arg.n)
+	tokens := float64(arg.n)
+	last := clock.Now()
+	return func() (interface{}, bool) {
+		it, ok := next()
+		if !ok {
+			return nil, false
+		}
+		now := clock.Now()
+		if interval > 0 {
+			tokens += float64(now.Sub(last)) / float64(interval)
+		}
+		if tokens > float64(arg.n) {
+			tokens = float64(arg.n)
+		}
+		last = now
+		if tokens < 1 {
+			wait := time.Duration((1 - tokens) * float64(interval))
+			clock.Sleep(wait)
+			last = last.Add(wait)
+			tokens = 1
+		}
+		tokens--
+		return it, true
+	}
+}
+
+func throttleStage(next iterator, op op, clock Clock) iterator {
+	arg := op.arg.(rateArg)
+	var last time.Time
+	started := false
+	return func() (interface{}, bool) {
+		for {
+			it, ok := next()
+			if !ok {
+				return nil, false
+			}
+			now := clock.Now()
+			if !started || now.Sub(last) >= arg.per {
+				started, last = true, now
+				return it, true
+			}
+		}
+	}
+}
+
+func debounceStage(next iterator, op op, clock Clock) iterator {
+	arg := op.arg.(rateArg)
+	var pending interface{}
+	var pendingAt time.Time
+	hasPending := false
+	return func() (interface{}, bool) {
+		for {
+			it, ok := next()
+			if !ok {
+				if hasPending {
+					hasPending = false
+					return pending, true
+				}
+				return nil, false
+			}
+			now := clock.Now()
+			prev, emit := pending, hasPending && now.Sub(pendingAt) >= arg.per
+			pending, pendingAt, hasPending = it, now, true
+			if emit {
+				return prev, true
+			}
+		}
+	}
+}
+
+func delayStage(next iterator, op op, clock Clock) iterator {
+	arg := op.arg.(rateArg)
+	return func() (interface{}, bool) {
+		it, ok := next()
+		if ok {
+			clock.Sleep(arg.per)
+		}
+		return it, ok
+	}
+}
@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose Sleep advances the time instantly.
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+	c.slept += d
+}
+
+// After fires at once, as if d had passed.
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.Sleep(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// arrive returns a stream of ints that arrive after the given gaps on the clock.
+func arrive(clock *fakeClock, gaps ...time.Duration) *Stream {
+	stream, _ := GenN(len(gaps), func(i int) int { return i })
+	return stream.WithClock(clock).Peek(func(i int) {
+		clock.now = clock.now.Add(gaps[i])
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := &fakeClock{}
+	stream, _ := GenN(10, func(i int) int { return i })
+	n := stream.WithClock(clock).RateLimit(2, time.Second).Count()
+	if n != 10 || clock.slept != 4*time.Second {
+		t.Errorf("expected 10 elements in 4s after a burst of 2, got %d in %v", n, clock.slept)
+	}
+	fmt.Println(t.Name()+":", clock.slept)
+}
+
+func TestThrottle(t *testing.T) {
+	clock := &fakeClock{}
+	ms := time.Millisecond
+	var result []int
+	arrive(clock, 0, 30*ms, 30*ms, 50*ms, 10*ms, 100*ms).Throttle(100 * ms).ToSlice(&result)
+	if fmt.Sprint(result) != "[0 3 5]" {
+		t.Errorf("unexpected throttled elements %v", result)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	clock := &fakeClock{}
+	ms := time.Millisecond
+	var result []int
+	arrive(clock, 0, 30*ms, 30*ms, 150*ms, 10*ms, 100*ms, 20*ms).Debounce(100 * ms).ToSlice(&result)
+	if fmt.Sprint(result) != "[2 4 6]" {
+		t.Errorf("unexpected debounced elements %v", result)
+	}
+	fmt.Println(t.Name()+":", result)
+}
+
+func TestDelay(t *testing.T) {
+	clock := &fakeClock{}
+	stream, _ := Ints(1, 2, 3)
+	if n := stream.WithClock(clock).Delay(time.Second).Count(); n != 3 || clock.slept != 3*time.Second {
+		t.Errorf("expected 3 elements in 3s, got %d in %v", n, clock.slept)
+	}
+}
@@ -0,0 +1,194 @@
+package stream
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+)
+
+// Entry is a file or directory found by Walk and WalkFS.
+type Entry struct {
+	// Path is the path of the entry, joined with the root.
+	Path string
+	// DirEntry is the entry as read from its directory.
+	DirEntry fs.DirEntry
+	// Info describes the entry, or the target of a symbolic link that is followed.
+	Info fs.FileInfo
+	// Depth is the number of directories between the entry and the root, 0 for the root itself.
+	Depth int
+}
+
+// WalkOptions configures Walk and WalkFS.
+type WalkOptions struct {
+	// MaxDepth stops walking below the given depth, 0 means no limit.
+	MaxDepth int
+	// FollowSymlinks walks into symbolic links to directories, links that lead back to a parent are not followed.
+	FollowSymlinks bool
+	// Pattern only yields the entries whose base name matches the glob pattern, see path.Match.
+	// Directories that don't match are still walked.
+	Pattern string
+	// OnError decides what happens when a directory or an entry can't be read.
+	OnError ErrorPolicy
+}
+
+// walkFrame is a directory being walked.
+type walkFrame struct {
+	dir       string
+	depth     int
+	entries   []fs.DirEntry
+	ancestors []fs.FileInfo
+}
+
+// walker walks a file tree in lexical order, directories are yielded before their contents.
+type walker struct {
+	readDir func(name string) ([]fs.DirEntry, error)
+	stat    func(name string) (fs.FileInfo, error)
+	lstat   func(name string) (fs.FileInfo, error)
+	join    func(elem ...string) string
+	opts    WalkOptions
+	root    string
+	stack   []*walkFrame
+}
+
+// Walk create a stream of the entries of the file tree rooted at root, including root itself.
+// The tree is read lazily, so the stream can be consumed only once. opts may be nil.
+func Walk(root string, opts *WalkOptions) (*Stream, error) {
+	return walk(&walker{
+		readDir: os.ReadDir,
+		stat:    os.Stat,
+		lstat:   os.Lstat,
+		join:    filepath.Join,
+		root:    root,
+	}, opts)
+}
+
+// WalkFS create a stream of the entries of the file tree rooted at root in fsys, including root itself.
+// The tree is read lazily, so the stream can be consumed only once. opts may be nil.
+func WalkFS(fsys fs.FS, root string, opts *WalkOptions) (*Stream, error) {
+	stat := func(name string) (fs.FileInfo, error) { return fs.Stat(fsys, name) }
+	return walk(&walker{
+		readDir: func(name string) ([]fs.DirEntry, error) { return fs.ReadDir(fsys, name) },
+		stat:    stat,
+		lstat:   stat,
+		join:    path.Join,
+		root:    root,
+	}, opts)
+}
+
+func walk(w *walker, opts *WalkOptions) (*Stream, error) {
+	if opts != nil {
+		w.opts = *opts
+	}
+	if _, err := path.Match(w.opts.Pattern, ""); err != nil {
+		return nil, err
+	}
+	s := &Stream{ops: make([]op, 0), res: reflect.TypeOf(Entry{})}
+	started := false
+	s.src = &source{next: func() (interface{}, bool, error) {
+		visit := w.visitNext
+		if !started {
+			started = true
+			visit = w.visitRoot
+		}
+		for {
+			entry, ok, err := visit()
+			if err != nil {
+				if err := s.handle(w.opts.OnError, err); err != nil {
+					return nil, false, err
+				}
+			}
+			if ok {
+				return entry, true, nil
+			}
+			if len(w.stack) == 0 {
+				return nil, false, nil
+			}
+			visit = w.visitNext
+		}
+	}}
+	return s, nil
+}
+
+func (w *walker) visitRoot() (interface{}, bool, error) {
+	lstat := w.lstat
+	if w.opts.FollowSymlinks {
+		lstat = w.stat
+	}
+	info, err := lstat(w.root)
+	if err != nil {
+		return nil, false, err
+	}
+	entry := Entry{Path: w.root, DirEntry: infoDirEntry{info}, Info: info}
+	err = w.enter(&entry, nil)
+	return entry, w.match(entry), err
+}
+
+// visitNext reads the next entry of the top directory, ok is false if it doesn't match or the directory is done.
+func (w *walker) visitNext() (interface{}, bool, error) {
+	top := w.stack[len(w.stack)-1]
+	if len(top.entries) == 0 {
+		w.stack = w.stack[:len(w.stack)-1]
+		return nil, false, nil
+	}
+	e := top.entries[0]
+	top.entries = top.entries[1:]
+
+	entry := Entry{Path: w.join(top.dir, e.Name()), DirEntry: e, Depth: top.depth + 1}
+	info, err := e.Info()
+	if err != nil {
+		return nil, false, err
+	}
+	entry.Info = info
+	if e.Type()&fs.ModeSymlink != 0 && w.opts.FollowSymlinks {
+		target, err := w.stat(entry.Path)
+		if err != nil {
+			return nil, false, err
+		}
+		entry.Info = target
+		for _, a := range top.ancestors {
+			if os.SameFile(a, target) {
+				return entry, w.match(entry), nil
+			}
+		}
+	}
+	err = w.enter(&entry, top.ancestors)
+	return entry, w.match(entry), err
+}
+
+// enter pushes the entry on the stack if it is a directory to walk.
+func (w *walker) enter(entry *Entry, ancestors []fs.FileInfo) error {
+	if !entry.Info.IsDir() || (w.opts.MaxDepth > 0 && entry.Depth >= w.opts.MaxDepth) {
+		return nil
+	}
+	entries, err := w.readDir(entry.Path)
+	if err != nil {
+		return err
+	}
+	w.stack = append(w.stack, &walkFrame{
+		dir:       entry.Path,
+		depth:     entry.Depth,
+		entries:   entries,
+		ancestors: append(ancestors[:len(ancestors):len(ancestors)], entry.Info),
+	})
+	return nil
+}
+
+func (w *walker) match(entry Entry) bool {
+	if w.opts.Pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(w.opts.Pattern, path.Base(filepath.ToSlash(entry.Path)))
+	return ok
+}
+
+// infoDirEntry is the fs.DirEntry of the root, which isn't read from a directory.
+type infoDirEntry struct {
+	info fs.FileInfo
+}
+
+func (e infoDirEntry) Name() string               { return e.info.Name() }
+func (e infoDirEntry) IsDir() bool                { return e.info.IsDir() }
+func (e infoDirEntry) Type() fs.FileMode          { return e.info.Mode().Type() }
+func (e infoDirEntry) Info() (fs.FileInfo, error) { return e.info, nil }
@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+var testFS = fstest.MapFS{
+	"root/a.txt":       {Data: []byte("hello")},
+	"root/b.log":       {Data: []byte("hello world")},
+	"root/sub/c.txt":   {Data: []byte("abc")},
+	"root/sub/d/e.txt": {Data: []byte("e")},
+}
+
+func walkPaths(t *testing.T, stream *Stream) []string {
+	var paths []string
+	stream.Map(func(e Entry) string {
+		return e.Path
+	}).ToSlice(&paths)
+	if stream.Err() != nil {
+		t.Fatal(stream.Err())
+	}
+	return paths
+}
+
+func TestWalkFS(t *testing.T) {
+	fmt.Println(t.Name() + ":")
+	stream, _ := WalkFS(testFS, "root", nil)
+	paths := walkPaths(t, stream)
+	expected := "[root root/a.txt root/b.log root/sub root/sub/c.txt root/sub/d root/sub/d/e.txt]"
+	if fmt.Sprint(paths) != expected {
+		t.Errorf("expected %s, got %v", expected, paths)
+	}
+
+	stream, _ = WalkFS(testFS, "root", &WalkOptions{MaxDepth: 2, Pattern: "*.txt"})
+	paths = walkPaths(t, stream)
+	if fmt.Sprint(paths) != "[root/a.txt root/sub/c.txt]" {
+		t.Errorf("unexpected paths %v", paths)
+	}
+	fmt.Printf("\t%v\n", paths)
+}
+
+func TestWalkFSGroup(t *testing.T) {
+	stream, _ := WalkFS(testFS, "root", nil)
+	group := stream.Filter(func(e Entry) bool {
+		return !e.DirEntry.IsDir()
+	}).Group(func(e Entry) (string, int64) {
+		return filepath.Ext(e.Path), e.Info.Size()
+	})
+	fmt.Println(t.Name()+":", group)
+}
+
+func TestWalkFSErr(t *testing.T) {
+	stream, _ := WalkFS(testFS, "missing", nil)
+	if n := stream.Count(); n != 0 || stream.Err() == nil {
+		t.Errorf("count %d, err %v", n, stream.Err())
+	}
+	stream, _ = WalkFS(testFS, "missing", &WalkOptions{OnError: ErrorCollect})
+	if n := stream.Count(); n != 0 || stream.Err() != nil || len(stream.Errors()) != 1 {
+		t.Errorf("count %d, err %v, errors %v", n, stream.Err(), stream.Errors())
+	}
+
+	_, err := WalkFS(testFS, "root", &WalkOptions{Pattern: "["})
+	fmt.Println(t.Name()+":", err)
+}
+
+func TestWalkSymlinks(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "dir", "sub"), 0755)
+	os.WriteFile(filepath.Join(root, "dir", "sub", "f.txt"), []byte("f"), 0644)
+	if err := os.Symlink(filepath.Join(root, "dir"), filepath.Join(root, "link")); err != nil {
+		t.Skip(err)
+	}
+	os.Symlink(root, filepath.Join(root, "dir", "loop"))
+
+	stream, _ := Walk(root, &WalkOptions{Pattern: "f.txt"})
+	if n := stream.Count(); n != 1 {
+		t.Errorf("expected 1 file without following links, got %d", n)
+	}
+
+	stream, _ = Walk(root, &WalkOptions{Pattern: "f.txt", FollowSymlinks: true})
+	paths := walkPaths(t, stream)
+	if len(paths) != 2 {
+		t.Errorf("expected 2 files following links, got %v", paths)
+	}
+}